@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/any-lyu/go.library/errors"
+	"github.com/any-lyu/go.library/json"
+)
+
+// ErrNil indicates that a reply value is nil, e.g. a missing field or member.
+var ErrNil = redis.ErrNil
+
+// Codec encodes values stored in redis by the typed wrappers.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// RawCodec stores strings, bytes, numbers and bools as their plain text
+	// form, the same way the Client methods do.
+	RawCodec Codec = rawCodec{}
+	// JSONCodec stores values as json.
+	JSONCodec Codec = jsonCodec{}
+)
+
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case int:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(nil, v, 10), nil
+	case float64:
+		return strconv.AppendFloat(nil, v, 'g', -1, 64), nil
+	case bool:
+		if v {
+			return []byte("1"), nil
+		}
+		return []byte("0"), nil
+	case nil:
+		return []byte{}, nil
+	default:
+		return []byte(fmt.Sprint(v)), nil
+	}
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	_, err := redis.Scan([]interface{}{data}, v)
+	return err
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func marshalAll(codec Codec, args redis.Args, values []interface{}) (redis.Args, error) {
+	for _, v := range values {
+		b, err := codec.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, b)
+	}
+	return args, nil
+}
+
+// decodeSlice decodes values into dst, which must be a pointer to a slice.
+func decodeSlice(codec Codec, values [][]byte, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return errors.Errorf("redis: decode dst must be a pointer to slice, got %T", dst)
+	}
+	sv := reflect.MakeSlice(rv.Elem().Type(), len(values), len(values))
+	for i, b := range values {
+		if err := codec.Unmarshal(b, sv.Index(i).Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	rv.Elem().Set(sv)
+	return nil
+}
+
+// decodeMap decodes field/value pairs into dst, which must be a pointer to a
+// map with string keys.
+func decodeMap(codec Codec, pairs [][]byte, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Map || rv.Elem().Type().Key().Kind() != reflect.String {
+		return errors.Errorf("redis: decode dst must be a pointer to map[string]T, got %T", dst)
+	}
+	if len(pairs)%2 != 0 {
+		return errors.New("redis: decode expects even number of values")
+	}
+	mt := rv.Elem().Type()
+	mv := reflect.MakeMapWithSize(mt, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		ev := reflect.New(mt.Elem())
+		if err := codec.Unmarshal(pairs[i+1], ev.Interface()); err != nil {
+			return err
+		}
+		mv.SetMapIndex(reflect.ValueOf(string(pairs[i])).Convert(mt.Key()), ev.Elem())
+	}
+	rv.Elem().Set(mv)
+	return nil
+}
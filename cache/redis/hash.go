@@ -0,0 +1,163 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Hash is a redis hash bound to a key, values are encoded by a Codec.
+type Hash struct {
+	pool  *Client
+	key   string
+	codec Codec
+}
+
+// NewHash returns the hash stored at key, if codec nil use RawCodec.
+func NewHash(pool *Client, key string, codec Codec) *Hash {
+	if codec == nil {
+		codec = RawCodec
+	}
+	return &Hash{pool: pool, key: key, codec: codec}
+}
+
+// Key returns the key of the hash.
+func (h *Hash) Key() string { return h.key }
+
+// Set HSET 设置 field 的值, 返回 field 是否是新建的.
+func (h *Hash) Set(field string, value interface{}) (created bool, err error) {
+	b, err := h.codec.Marshal(value)
+	if err != nil {
+		return
+	}
+	conn := h.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Bool(conn.Do("HSET", h.key, field, b))
+}
+
+// SetNX HSETNX 只在 field 不存在时设置, 返回是否设置成功.
+func (h *Hash) SetNX(field string, value interface{}) (ok bool, err error) {
+	b, err := h.codec.Marshal(value)
+	if err != nil {
+		return
+	}
+	conn := h.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Bool(conn.Do("HSETNX", h.key, field, b))
+}
+
+// MSet HMSET 同时设置多个 field, fields 为 map[string]T.
+func (h *Hash) MSet(fields map[string]interface{}) (err error) {
+	args := redis.Args{h.key}
+	for field, value := range fields {
+		b, err := h.codec.Marshal(value)
+		if err != nil {
+			return err
+		}
+		args = append(args, field, b)
+	}
+	conn := h.pool.Pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("HMSET", args...)
+	return err
+}
+
+// Get HGET 将 field 的值解码到 v, field 不存在时返回 ErrNil.
+func (h *Hash) Get(field string, v interface{}) (err error) {
+	conn := h.pool.Pool.Get()
+	defer conn.Close()
+
+	b, err := redis.Bytes(conn.Do("HGET", h.key, field))
+	if err != nil {
+		return err
+	}
+	return h.codec.Unmarshal(b, v)
+}
+
+// MGet HMGET 将多个 field 的值解码到 dst, dst 为 *map[string]T, 不存在的 field 被忽略.
+func (h *Hash) MGet(dst interface{}, fields ...string) (err error) {
+	conn := h.pool.Pool.Get()
+	defer conn.Close()
+
+	values, err := redis.ByteSlices(conn.Do("HMGET", redis.Args{h.key}.AddFlat(fields)...))
+	if err != nil {
+		return err
+	}
+	pairs := make([][]byte, 0, len(values)*2)
+	for i, b := range values {
+		if b != nil {
+			pairs = append(pairs, []byte(fields[i]), b)
+		}
+	}
+	return decodeMap(h.codec, pairs, dst)
+}
+
+// GetAll HGETALL 将所有 field 解码到 dst, dst 为 *map[string]T.
+//
+// 大的 hash 请使用 Scan.
+func (h *Hash) GetAll(dst interface{}) (err error) {
+	conn := h.pool.Pool.Get()
+	defer conn.Close()
+
+	pairs, err := redis.ByteSlices(conn.Do("HGETALL", h.key))
+	if err != nil {
+		return err
+	}
+	return decodeMap(h.codec, pairs, dst)
+}
+
+// Del HDEL 删除一个或多个 field, 返回被删除的数量.
+func (h *Hash) Del(fields ...string) (num int, err error) {
+	conn := h.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int(conn.Do("HDEL", redis.Args{h.key}.AddFlat(fields)...))
+}
+
+// Exists HEXISTS 检查 field 是否存在.
+func (h *Hash) Exists(field string) (ok bool, err error) {
+	conn := h.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Bool(conn.Do("HEXISTS", h.key, field))
+}
+
+// Len HLEN 返回 field 的数量.
+func (h *Hash) Len() (num int, err error) {
+	conn := h.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int(conn.Do("HLEN", h.key))
+}
+
+// Fields HKEYS 返回所有 field.
+func (h *Hash) Fields() (fields []string, err error) {
+	conn := h.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Strings(conn.Do("HKEYS", h.key))
+}
+
+// IncrBy HINCRBY 为 field 加上 incr, 返回加后的值.
+func (h *Hash) IncrBy(field string, incr int64) (num int64, err error) {
+	conn := h.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int64(conn.Do("HINCRBY", h.key, field, incr))
+}
+
+// IncrByFloat HINCRBYFLOAT 为 field 加上浮点数 incr, 返回加后的值.
+func (h *Hash) IncrByFloat(field string, incr float64) (num float64, err error) {
+	conn := h.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Float64(conn.Do("HINCRBYFLOAT", h.key, field, incr))
+}
+
+// Scan HSCAN 迭代所有 field, Iterator.Key 为 field, Iterator.Decode 解码 value.
+func (h *Hash) Scan(ctx context.Context, opt *ScanOptions) *Iterator {
+	return newIterator(ctx, h.pool, "HSCAN", h.key, opt, h.codec)
+}
@@ -0,0 +1,132 @@
+package redis
+
+import (
+	"github.com/garyburd/redigo/redis"
+)
+
+// List is a redis list bound to a key, elements are encoded by a Codec.
+type List struct {
+	pool  *Client
+	key   string
+	codec Codec
+}
+
+// NewList returns the list stored at key, if codec nil use RawCodec.
+func NewList(pool *Client, key string, codec Codec) *List {
+	if codec == nil {
+		codec = RawCodec
+	}
+	return &List{pool: pool, key: key, codec: codec}
+}
+
+// Key returns the key of the list.
+func (l *List) Key() string { return l.key }
+
+// LPush LPUSH 将元素插入到表头, 返回 push 后的长度.
+func (l *List) LPush(values ...interface{}) (num int, err error) {
+	return l.push("LPUSH", values)
+}
+
+// RPush RPUSH 将元素插入到表尾, 返回 push 后的长度.
+func (l *List) RPush(values ...interface{}) (num int, err error) {
+	return l.push("RPUSH", values)
+}
+
+func (l *List) push(cmd string, values []interface{}) (num int, err error) {
+	args, err := marshalAll(l.codec, redis.Args{l.key}, values)
+	if err != nil {
+		return
+	}
+	conn := l.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int(conn.Do(cmd, args...))
+}
+
+// LPop LPOP 弹出表头元素并解码到 v, 列表为空时返回 ErrNil.
+func (l *List) LPop(v interface{}) (err error) {
+	return l.pop("LPOP", v)
+}
+
+// RPop RPOP 弹出表尾元素并解码到 v, 列表为空时返回 ErrNil.
+func (l *List) RPop(v interface{}) (err error) {
+	return l.pop("RPOP", v)
+}
+
+func (l *List) pop(cmd string, v interface{}) (err error) {
+	conn := l.pool.Pool.Get()
+	defer conn.Close()
+
+	b, err := redis.Bytes(conn.Do(cmd, l.key))
+	if err != nil {
+		return err
+	}
+	return l.codec.Unmarshal(b, v)
+}
+
+// Index LINDEX 将下标为 index 的元素解码到 v, 越界时返回 ErrNil.
+func (l *List) Index(index int64, v interface{}) (err error) {
+	conn := l.pool.Pool.Get()
+	defer conn.Close()
+
+	b, err := redis.Bytes(conn.Do("LINDEX", l.key, index))
+	if err != nil {
+		return err
+	}
+	return l.codec.Unmarshal(b, v)
+}
+
+// SetIndex LSET 设置下标为 index 的元素.
+func (l *List) SetIndex(index int64, v interface{}) (err error) {
+	b, err := l.codec.Marshal(v)
+	if err != nil {
+		return
+	}
+	conn := l.pool.Pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("LSET", l.key, index, b)
+	return err
+}
+
+// Len LLEN 返回列表的长度.
+func (l *List) Len() (num int, err error) {
+	conn := l.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int(conn.Do("LLEN", l.key))
+}
+
+// Range LRANGE 将 [start, stop] 区间内的元素解码到 dst, dst 为 *[]T.
+func (l *List) Range(start, stop int64, dst interface{}) (err error) {
+	conn := l.pool.Pool.Get()
+	defer conn.Close()
+
+	values, err := redis.ByteSlices(conn.Do("LRANGE", l.key, start, stop))
+	if err != nil {
+		return err
+	}
+	return decodeSlice(l.codec, values, dst)
+}
+
+// Trim LTRIM 只保留 [start, stop] 区间内的元素.
+func (l *List) Trim(start, stop int64) (err error) {
+	conn := l.pool.Pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("LTRIM", l.key, start, stop)
+	return err
+}
+
+// Remove LREM 移除 count 个等于 v 的元素, 返回移除的数量.
+// count > 0 从表头开始, count < 0 从表尾开始, count = 0 移除所有.
+func (l *List) Remove(count int64, v interface{}) (num int, err error) {
+	b, err := l.codec.Marshal(v)
+	if err != nil {
+		return
+	}
+	conn := l.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int(conn.Do("LREM", l.key, count, b))
+}
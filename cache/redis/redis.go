@@ -115,6 +115,8 @@ func (pool *Client) EXISTS(key string) (ok bool, err error) {
 }
 
 // KEYS redis range key
+//
+// Deprecated: KEYS blocks redis on large keyspaces, use Scan.
 func (pool *Client) KEYS(pattern string) (keys []string, err error) {
 	conn := pool.Pool.Get()
 	defer conn.Close()
@@ -250,6 +252,8 @@ func (pool *Client) HMGETMAP(key string) (map[string]string, error) {
 }
 
 // HMGETINTMAP 返回hash表中所有字段 并映射为map[string]int
+//
+// Deprecated: use Hash.GetAll.
 func (pool *Client) HMGETINTMAP(key string) (map[string]int, error) {
 	conn := pool.Pool.Get()
 	defer conn.Close()
@@ -259,6 +263,8 @@ func (pool *Client) HMGETINTMAP(key string) (map[string]int, error) {
 }
 
 // HMGETINT64MAP 返回hash表中所有字段 并映射为map[string]int64
+//
+// Deprecated: use Hash.GetAll.
 func (pool *Client) HMGETINT64MAP(key string) (map[string]int64, error) {
 	conn := pool.Pool.Get()
 	defer conn.Close()
@@ -428,6 +434,8 @@ func (pool *Client) ZREVRANGEBYSCORE(key string, limit int) (list map[string]str
 }
 
 // ZREVRANGEBYSCORE2 ZREVRANGEBYSCORE 逆序份数  获取start len的数据
+//
+// Deprecated: use SortedSet.RangeWithScores.
 func (pool *Client) ZREVRANGEBYSCORE2(key string, start, len int) (list map[string]int, err error) {
 	conn := pool.Pool.Get()
 	defer conn.Close()
@@ -437,6 +445,8 @@ func (pool *Client) ZREVRANGEBYSCORE2(key string, start, len int) (list map[stri
 }
 
 // ZREVRANGEBYSCORE3 ZREVRANGEBYSCORE 逆序份数  获取start len的数据
+//
+// Deprecated: use SortedSet.RangeWithScores.
 func (pool *Client) ZREVRANGEBYSCORE3(key string, start, len int) (list map[string]float64, err error) {
 	conn := pool.Pool.Get()
 	defer conn.Close()
@@ -478,6 +488,8 @@ func (pool *Client) GetSearchKeys(key string, limit int) (list []string, err err
 }
 
 // GetSearchKeys2 ZREVRANGEBYSCORE 逆序份数  获取的 start,len 不要scores
+//
+// Deprecated: use SortedSet.Range.
 func (pool *Client) GetSearchKeys2(key string, start, len int) (list []string, err error) {
 	conn := pool.Pool.Get()
 	defer conn.Close()
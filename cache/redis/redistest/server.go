@@ -0,0 +1,203 @@
+// Package redistest provides a redis protocol stand-in for tests.
+//
+// A Server accepts RESP connections on a loopback port and hands every
+// command to a HandlerFunc, so tests can assert the exact wire command and
+// script the reply without a running redis.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Status is a simple string reply, e.g. Status("OK") is written as +OK.
+type Status string
+
+// Error is an error reply, e.g. Error("ERR boom") is written as -ERR boom.
+type Error string
+
+// HandlerFunc serves one command. cmd is upper-cased, args are the remaining
+// arguments. The returned value is encoded as the reply:
+//
+//	nil               null bulk string
+//	Status            simple string
+//	Error             error
+//	int, int64        integer
+//	string, []byte    bulk string
+//	float64           bulk string
+//	[]string          array of bulk strings
+//	[]interface{}     array, elements encoded recursively
+type HandlerFunc func(cmd string, args []string) interface{}
+
+// Server is a RESP server backed by a HandlerFunc.
+type Server struct {
+	Addr string
+
+	ln      net.Listener
+	handler HandlerFunc
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	wg      sync.WaitGroup
+}
+
+// NewServer starts a Server listening on a random loopback port.
+func NewServer(h HandlerFunc) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("redistest: failed to listen on a port: %v", err))
+	}
+	s := &Server{
+		Addr:    ln.Addr().String(),
+		ln:      ln,
+		handler: h,
+		conns:   make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Pool returns a redis pool dialing the server.
+func (s *Server) Pool() *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:     4,
+		IdleTimeout: time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", s.Addr)
+		},
+	}
+}
+
+// Close stops accepting and closes all open connections.
+func (s *Server) Close() {
+	s.ln.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.serveConn(c)
+	}
+}
+
+func (s *Server) serveConn(c net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+		s.wg.Done()
+	}()
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		writeReply(w, s.handler(strings.ToUpper(args[0]), args[1:]))
+		if err = w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		// inline command
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		if line, err = readLine(r); err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("redistest: expect bulk string, got %q", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func writeReply(w *bufio.Writer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case Status:
+		fmt.Fprintf(w, "+%s\r\n", v)
+	case Error:
+		fmt.Fprintf(w, "-%s\r\n", v)
+	case int:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []byte:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case float64:
+		writeReply(w, strconv.FormatFloat(v, 'f', -1, 64))
+	case []string:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, s := range v {
+			writeReply(w, s)
+		}
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, e := range v {
+			writeReply(w, e)
+		}
+	default:
+		writeReply(w, Error(fmt.Sprintf("ERR redistest: unsupported reply type %T", v)))
+	}
+}
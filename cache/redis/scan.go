@@ -0,0 +1,213 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/any-lyu/go.library/errors"
+)
+
+// ScanOptions SCAN family options.
+type ScanOptions struct {
+	Match string // glob-style pattern, empty matches everything
+	Count int    // hint of elements returned per call, 0 uses the redis default
+	Type  string // SCAN only, e.g. "string", "hash", "zset", redis 6.0+
+}
+
+// Iterator iterates over a cursor based SCAN family command, fetching the
+// next page lazily. It does not block redis the way KEYS or HGETALL do, but
+// elements added or removed during the iteration may or may not be returned,
+// and an element may be returned more than once.
+//
+// The iteration stops with ctx.Err() once the context is done.
+//
+//	it := pool.Scan(ctx, &ScanOptions{Match: "user:*"})
+//	for it.Next() {
+//		key := it.Key()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type Iterator struct {
+	ctx   context.Context
+	pool  *Client
+	cmd   string
+	key   string
+	opt   ScanOptions
+	codec Codec
+	step  int
+
+	cursor  uint64
+	started bool
+	page    [][]byte
+	cur     [][]byte
+	err     error
+}
+
+func newIterator(ctx context.Context, pool *Client, cmd, key string, opt *ScanOptions, codec Codec) *Iterator {
+	it := &Iterator{ctx: ctx, pool: pool, cmd: cmd, key: key, codec: codec, step: 1}
+	if cmd == "HSCAN" || cmd == "ZSCAN" {
+		it.step = 2
+	}
+	if opt != nil {
+		it.opt = *opt
+	}
+	return it
+}
+
+// Scan SCAN 迭代所有匹配的 key, 用于替代 KEYS.
+func (pool *Client) Scan(ctx context.Context, opt *ScanOptions) *Iterator {
+	return newIterator(ctx, pool, "SCAN", "", opt, RawCodec)
+}
+
+// HScan HSCAN 迭代 hash 的 field, Iterator.Value 为 field 的值.
+func (pool *Client) HScan(ctx context.Context, key string, opt *ScanOptions) *Iterator {
+	return newIterator(ctx, pool, "HSCAN", key, opt, RawCodec)
+}
+
+// SScan SSCAN 迭代集合的 member.
+func (pool *Client) SScan(ctx context.Context, key string, opt *ScanOptions) *Iterator {
+	return newIterator(ctx, pool, "SSCAN", key, opt, RawCodec)
+}
+
+// ZScan ZSCAN 迭代有序集合的 member, Iterator.Score 为 member 的 score.
+func (pool *Client) ZScan(ctx context.Context, key string, opt *ScanOptions) *Iterator {
+	return newIterator(ctx, pool, "ZSCAN", key, opt, RawCodec)
+}
+
+// DeleteMatch 迭代所有匹配 pattern 的 key, 每 batch 个 UNLINK 一次, 返回删除的数量.
+// UNLINK 需要 redis 4.0+. pattern 不能为空, 避免误删所有 key.
+func (pool *Client) DeleteMatch(ctx context.Context, pattern string, batch int) (num int, err error) {
+	if pattern == "" {
+		return 0, errors.New("redis: DeleteMatch empty pattern")
+	}
+	if batch <= 0 {
+		batch = 100
+	}
+	keys := make([]interface{}, 0, batch)
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		conn, err := pool.Pool.GetContext(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		n, err := redis.Int(conn.Do("UNLINK", keys...))
+		num += n
+		keys = keys[:0]
+		return err
+	}
+	it := pool.Scan(ctx, &ScanOptions{Match: pattern, Count: batch})
+	for it.Next() {
+		if keys = append(keys, it.Key()); len(keys) >= batch {
+			if err = flush(); err != nil {
+				return num, errors.Wrapf(err, "DeleteMatch pattern:%s", pattern)
+			}
+		}
+	}
+	if err = it.Err(); err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return num, errors.Wrapf(err, "DeleteMatch pattern:%s", pattern)
+	}
+	return num, nil
+}
+
+// Next advances to the next element, it returns false when the iteration is
+// done or an error occurred.
+func (it *Iterator) Next() bool {
+	for len(it.page) < it.step {
+		if it.err != nil || (it.started && it.cursor == 0) {
+			return false
+		}
+		it.fetch()
+	}
+	it.cur, it.page = it.page[:it.step], it.page[it.step:]
+	return true
+}
+
+func (it *Iterator) fetch() {
+	if it.err = it.ctx.Err(); it.err != nil {
+		return
+	}
+	args := redis.Args{}
+	if it.key != "" {
+		args = append(args, it.key)
+	}
+	args = append(args, it.cursor)
+	if it.opt.Match != "" {
+		args = append(args, "MATCH", it.opt.Match)
+	}
+	if it.opt.Count > 0 {
+		args = append(args, "COUNT", it.opt.Count)
+	}
+	if it.opt.Type != "" && it.cmd == "SCAN" {
+		args = append(args, "TYPE", it.opt.Type)
+	}
+	conn, err := it.pool.Pool.GetContext(it.ctx)
+	if err != nil {
+		it.err = err
+		return
+	}
+	defer conn.Close()
+
+	values, err := redis.Values(conn.Do(it.cmd, args...))
+	if err == nil && len(values) != 2 {
+		err = errors.Errorf("redis: %s expects two values, got %d", it.cmd, len(values))
+	}
+	if err != nil {
+		it.err = err
+		return
+	}
+	var cursor []byte
+	if cursor, err = redis.Bytes(values[0], nil); err == nil {
+		it.cursor, err = strconv.ParseUint(string(cursor), 10, 64)
+	}
+	if err == nil {
+		it.page, err = redis.ByteSlices(values[1], nil)
+	}
+	it.started = true
+	it.err = err
+}
+
+// Key returns the current key, set member or hash field.
+func (it *Iterator) Key() string {
+	return string(it.cur[0])
+}
+
+// Value returns the current hash value, or nil for the other commands.
+func (it *Iterator) Value() []byte {
+	if it.cmd != "HSCAN" {
+		return nil
+	}
+	return it.cur[1]
+}
+
+// Score returns the current sorted set score, or 0 for the other commands.
+func (it *Iterator) Score() float64 {
+	if it.cmd != "ZSCAN" {
+		return 0
+	}
+	score, _ := strconv.ParseFloat(string(it.cur[1]), 64)
+	return score
+}
+
+// Decode decodes the current hash value, or the current key or member for
+// the other commands, with the codec of the object that created the
+// iterator.
+func (it *Iterator) Decode(v interface{}) error {
+	if it.cmd == "HSCAN" {
+		return it.codec.Unmarshal(it.cur[1], v)
+	}
+	return it.codec.Unmarshal(it.cur[0], v)
+}
+
+// Err returns the error stopped the iteration, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,105 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScan(t *testing.T) {
+	c, closeFn := expect(t, map[string]interface{}{
+		"SCAN 0 MATCH user:* COUNT 2 TYPE hash": []interface{}{"7", []string{"user:1", "user:2"}},
+		"SCAN 7 MATCH user:* COUNT 2 TYPE hash": []interface{}{"0", []string{"user:3"}},
+	})
+	defer closeFn()
+
+	var keys []string
+	it := c.Scan(context.Background(), &ScanOptions{Match: "user:*", Count: 2, Type: "hash"})
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, []string{"user:1", "user:2", "user:3"}, keys)
+}
+
+func TestScanPairs(t *testing.T) {
+	c, closeFn := expect(t, map[string]interface{}{
+		"HSCAN h 0":             []interface{}{"0", []string{"f1", `{"name":"a","age":1}`}},
+		"SSCAN s 0 MATCH a*":    []interface{}{"0", []string{"a1", "a2"}},
+		"ZSCAN z 0 COUNT 10":    []interface{}{"3", []string{"m1", "1.5"}},
+		"ZSCAN z 3 COUNT 10":    []interface{}{"0", []string{"m2", "-2"}},
+		"HSCAN raw 0 MATCH f*":  []interface{}{"0", []string{"f1", "v1"}},
+		"SSCAN ints 0 COUNT 50": []interface{}{"0", []string{"42"}},
+	})
+	defer closeFn()
+	ctx := context.Background()
+
+	it := NewHash(c, "h", JSONCodec).Scan(ctx, nil)
+	assert.True(t, it.Next())
+	assert.Equal(t, "f1", it.Key())
+	var u user
+	assert.NoError(t, it.Decode(&u))
+	assert.Equal(t, "a", u.Name)
+	assert.False(t, it.Next())
+	assert.NoError(t, it.Err())
+
+	it = c.HScan(ctx, "raw", &ScanOptions{Match: "f*"})
+	assert.True(t, it.Next())
+	assert.Equal(t, []byte("v1"), it.Value())
+
+	var members []string
+	it = c.SScan(ctx, "s", &ScanOptions{Match: "a*", Type: "ignored"})
+	for it.Next() {
+		members = append(members, it.Key())
+		assert.Nil(t, it.Value())
+	}
+	assert.Equal(t, []string{"a1", "a2"}, members)
+
+	var n int
+	it = NewSet(c, "ints", nil).Scan(ctx, &ScanOptions{Count: 50})
+	assert.True(t, it.Next())
+	assert.NoError(t, it.Decode(&n))
+	assert.Equal(t, 42, n)
+
+	scores := map[string]float64{}
+	it = NewSortedSet(c, "z", nil).Scan(ctx, &ScanOptions{Count: 10})
+	for it.Next() {
+		var m string
+		assert.NoError(t, it.Decode(&m))
+		scores[m] = it.Score()
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, map[string]float64{"m1": 1.5, "m2": -2}, scores)
+}
+
+func TestScanContext(t *testing.T) {
+	c, closeFn := expect(t, map[string]interface{}{
+		"SCAN 0": []interface{}{"9", []string{"k1"}},
+	})
+	defer closeFn()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it := c.Scan(ctx, nil)
+	assert.True(t, it.Next())
+	cancel()
+	assert.False(t, it.Next())
+	assert.Equal(t, context.Canceled, it.Err())
+}
+
+func TestDeleteMatch(t *testing.T) {
+	c, closeFn := expect(t, map[string]interface{}{
+		"SCAN 0 MATCH tmp:* COUNT 2": []interface{}{"5", []string{"tmp:1", "tmp:2", "tmp:3"}},
+		"SCAN 5 MATCH tmp:* COUNT 2": []interface{}{"0", []string{}},
+		"UNLINK tmp:1 tmp:2":         2,
+		"UNLINK tmp:3":               1,
+	})
+	defer closeFn()
+
+	n, err := c.DeleteMatch(context.Background(), "tmp:*", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	_, err = c.DeleteMatch(context.Background(), "", 2)
+	assert.Error(t, err)
+}
@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Set is a redis set bound to a key, members are encoded by a Codec.
+type Set struct {
+	pool  *Client
+	key   string
+	codec Codec
+}
+
+// NewSet returns the set stored at key, if codec nil use RawCodec.
+func NewSet(pool *Client, key string, codec Codec) *Set {
+	if codec == nil {
+		codec = RawCodec
+	}
+	return &Set{pool: pool, key: key, codec: codec}
+}
+
+// Key returns the key of the set.
+func (s *Set) Key() string { return s.key }
+
+// Add SADD 添加一个或多个 member, 返回新增的数量.
+func (s *Set) Add(members ...interface{}) (num int, err error) {
+	args, err := marshalAll(s.codec, redis.Args{s.key}, members)
+	if err != nil {
+		return
+	}
+	conn := s.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int(conn.Do("SADD", args...))
+}
+
+// Remove SREM 移除一个或多个 member, 返回移除的数量.
+func (s *Set) Remove(members ...interface{}) (num int, err error) {
+	args, err := marshalAll(s.codec, redis.Args{s.key}, members)
+	if err != nil {
+		return
+	}
+	conn := s.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int(conn.Do("SREM", args...))
+}
+
+// IsMember SISMEMBER 判断 member 是否在集合中.
+func (s *Set) IsMember(member interface{}) (ok bool, err error) {
+	b, err := s.codec.Marshal(member)
+	if err != nil {
+		return
+	}
+	conn := s.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Bool(conn.Do("SISMEMBER", s.key, b))
+}
+
+// Card SCARD 返回集合中元素的数量.
+func (s *Set) Card() (num int, err error) {
+	conn := s.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int(conn.Do("SCARD", s.key))
+}
+
+// Members SMEMBERS 将所有 member 解码到 dst, dst 为 *[]T.
+//
+// 大的集合请使用 Scan.
+func (s *Set) Members(dst interface{}) (err error) {
+	conn := s.pool.Pool.Get()
+	defer conn.Close()
+
+	values, err := redis.ByteSlices(conn.Do("SMEMBERS", s.key))
+	if err != nil {
+		return err
+	}
+	return decodeSlice(s.codec, values, dst)
+}
+
+// Pop SPOP 随机弹出一个 member 并解码到 v, 集合为空时返回 ErrNil.
+func (s *Set) Pop(v interface{}) (err error) {
+	conn := s.pool.Pool.Get()
+	defer conn.Close()
+
+	b, err := redis.Bytes(conn.Do("SPOP", s.key))
+	if err != nil {
+		return err
+	}
+	return s.codec.Unmarshal(b, v)
+}
+
+// Scan SSCAN 迭代所有 member, Iterator.Decode 解码 member.
+func (s *Set) Scan(ctx context.Context, opt *ScanOptions) *Iterator {
+	return newIterator(ctx, s.pool, "SSCAN", s.key, opt, s.codec)
+}
@@ -0,0 +1,156 @@
+package redis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/any-lyu/go.library/cache/redis/redistest"
+)
+
+// expect serves the scripted replies keyed by the space joined command line
+// and fails on any other command.
+func expect(t *testing.T, replies map[string]interface{}) (*Client, func()) {
+	s := redistest.NewServer(func(cmd string, args []string) interface{} {
+		line := strings.Join(append([]string{cmd}, args...), " ")
+		reply, ok := replies[line]
+		if !ok {
+			t.Errorf("unexpected command %q", line)
+			return redistest.Error("ERR unexpected command")
+		}
+		return reply
+	})
+	return &Client{Pool: s.Pool()}, s.Close
+}
+
+type user struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestHash(t *testing.T) {
+	c, closeFn := expect(t, map[string]interface{}{
+		`HSET h u1 {"name":"a","age":1}`: 1,
+		"HGET h u1":                      `{"name":"a","age":1}`,
+		"HGET h u2":                      nil,
+		"HMGET h u1 u2":                  []interface{}{`{"name":"a","age":1}`, nil},
+		"HGETALL counts":                 []string{"a", "1", "b", "2"},
+		"HINCRBY counts a 5":             6,
+	})
+	defer closeFn()
+
+	h := NewHash(c, "h", JSONCodec)
+	created, err := h.Set("u1", user{Name: "a", Age: 1})
+	assert.NoError(t, err)
+	assert.True(t, created)
+
+	var u user
+	assert.NoError(t, h.Get("u1", &u))
+	assert.Equal(t, user{Name: "a", Age: 1}, u)
+	assert.Equal(t, ErrNil, h.Get("u2", &u))
+
+	var m map[string]user
+	assert.NoError(t, h.MGet(&m, "u1", "u2"))
+	assert.Equal(t, map[string]user{"u1": {Name: "a", Age: 1}}, m)
+
+	counts := NewHash(c, "counts", nil)
+	var im map[string]int64
+	assert.NoError(t, counts.GetAll(&im))
+	assert.Equal(t, map[string]int64{"a": 1, "b": 2}, im)
+	n, err := counts.IncrBy("a", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), n)
+	assert.Error(t, counts.GetAll(im))
+}
+
+func TestSortedSet(t *testing.T) {
+	c, closeFn := expect(t, map[string]interface{}{
+		"ZADD z NX 1 a 2.5 b": 2,
+		"ZADD z XX GT CH 3 a": 1,
+		"ZADD z LT 1 a":       0,
+		"ZRANGE z 0 -1":       []string{"a", "b"},
+		"ZRANGE z +inf (1 BYSCORE REV LIMIT 0 10 WITHSCORES": []string{"b", "2.5", "a", "1.5"},
+		"ZSCORE z c": nil,
+	})
+	defer closeFn()
+
+	z := NewSortedSet(c, "z", nil)
+	n, err := z.Add(&ZAddOptions{NX: true}, Z{Score: 1, Member: "a"}, Z{Score: 2.5, Member: "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	n, err = z.Add(&ZAddOptions{XX: true, GT: true, CH: true}, Z{Score: 3, Member: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = z.Add(&ZAddOptions{LT: true}, Z{Score: 1, Member: "a"})
+	assert.NoError(t, err)
+
+	var members []string
+	assert.NoError(t, z.Range(&ZRangeOptions{Start: 0, Stop: -1}, &members))
+	assert.Equal(t, []string{"a", "b"}, members)
+	members = nil
+	assert.NoError(t, z.Range(nil, &members))
+	assert.Equal(t, []string{"a", "b"}, members)
+
+	scores, err := z.RangeWithScores(&ZRangeOptions{Start: "+inf", Stop: "(1", ByScore: true, Rev: true, Count: 10}, &members)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "a"}, members)
+	assert.Equal(t, []float64{2.5, 1.5}, scores)
+
+	_, err = z.Score("c")
+	assert.Equal(t, ErrNil, err)
+}
+
+func TestSetAndList(t *testing.T) {
+	c, closeFn := expect(t, map[string]interface{}{
+		"SADD s 1 2 3":  3,
+		"SMEMBERS s":    []string{"1", "2", "3"},
+		"RPUSH l x y":   2,
+		"LRANGE l 0 -1": []string{"x", "y"},
+		"LPOP l":        "x",
+		"LPOP empty":    nil,
+		"LREM l 0 y":    1,
+		"SISMEMBER s 4": 0,
+		"LTRIM l 0 99":  redistest.Status("OK"),
+		"LINDEX l 5":    nil,
+		"SPOP s":        "2",
+		"SREM s 1 3":    2,
+		"LSET l 0 z":    redistest.Status("OK"),
+	})
+	defer closeFn()
+
+	s := NewSet(c, "s", nil)
+	n, err := s.Add(1, 2, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	var ints []int
+	assert.NoError(t, s.Members(&ints))
+	assert.Equal(t, []int{1, 2, 3}, ints)
+	ok, err := s.IsMember(4)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	var popped int
+	assert.NoError(t, s.Pop(&popped))
+	assert.Equal(t, 2, popped)
+	n, err = s.Remove(1, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	l := NewList(c, "l", nil)
+	n, err = l.RPush("x", "y")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	var strs []string
+	assert.NoError(t, l.Range(0, -1, &strs))
+	assert.Equal(t, []string{"x", "y"}, strs)
+	var head string
+	assert.NoError(t, l.LPop(&head))
+	assert.Equal(t, "x", head)
+	assert.Equal(t, ErrNil, NewList(c, "empty", nil).LPop(&head))
+	assert.Equal(t, ErrNil, l.Index(5, &head))
+	assert.NoError(t, l.SetIndex(0, "z"))
+	assert.NoError(t, l.Trim(0, 99))
+	n, err = l.Remove(0, "y")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
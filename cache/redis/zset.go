@@ -0,0 +1,251 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/any-lyu/go.library/errors"
+)
+
+// Z is a sorted set member with its score.
+type Z struct {
+	Score  float64
+	Member interface{}
+}
+
+// ZAddOptions ZADD options.
+type ZAddOptions struct {
+	NX bool // 只添加新成员, 不更新已有成员
+	XX bool // 只更新已有成员, 不添加新成员
+	GT bool // 只在新 score 大于当前 score 时更新, redis 6.2+
+	LT bool // 只在新 score 小于当前 score 时更新, redis 6.2+
+	CH bool // 返回变更(新增+更新)的数量, 而不是新增的数量
+}
+
+func (o *ZAddOptions) args(args redis.Args) redis.Args {
+	if o == nil {
+		return args
+	}
+	if o.NX {
+		args = append(args, "NX")
+	}
+	if o.XX {
+		args = append(args, "XX")
+	}
+	if o.GT {
+		args = append(args, "GT")
+	}
+	if o.LT {
+		args = append(args, "LT")
+	}
+	if o.CH {
+		args = append(args, "CH")
+	}
+	return args
+}
+
+// ZRangeOptions ZRANGE options, redis 6.2+.
+//
+// Start and Stop are indexes by default, scores such as 1, "(1", "-inf" with
+// ByScore, or lex bounds such as "[a", "-" with ByLex. A nil ZRangeOptions
+// ranges over the whole set.
+type ZRangeOptions struct {
+	Start, Stop interface{}
+	ByScore     bool
+	ByLex       bool
+	Rev         bool
+	// Offset and Count are the LIMIT, only with ByScore or ByLex, Count 0
+	// means no limit.
+	Offset, Count int64
+}
+
+func (o *ZRangeOptions) args(args redis.Args) redis.Args {
+	if o == nil {
+		return append(args, 0, -1)
+	}
+	args = append(args, o.Start, o.Stop)
+	if o.ByScore {
+		args = append(args, "BYSCORE")
+	} else if o.ByLex {
+		args = append(args, "BYLEX")
+	}
+	if o.Rev {
+		args = append(args, "REV")
+	}
+	if o.Count != 0 {
+		args = append(args, "LIMIT", o.Offset, o.Count)
+	}
+	return args
+}
+
+// SortedSet is a redis sorted set bound to a key, members are encoded by a
+// Codec.
+type SortedSet struct {
+	pool  *Client
+	key   string
+	codec Codec
+}
+
+// NewSortedSet returns the sorted set stored at key, if codec nil use RawCodec.
+func NewSortedSet(pool *Client, key string, codec Codec) *SortedSet {
+	if codec == nil {
+		codec = RawCodec
+	}
+	return &SortedSet{pool: pool, key: key, codec: codec}
+}
+
+// Key returns the key of the sorted set.
+func (z *SortedSet) Key() string { return z.key }
+
+// Add ZADD 添加或更新成员, opt 可以为 nil.
+// 返回新增的数量, opt.CH 时返回变更的数量.
+func (z *SortedSet) Add(opt *ZAddOptions, members ...Z) (num int64, err error) {
+	args := opt.args(redis.Args{z.key})
+	for _, m := range members {
+		b, err := z.codec.Marshal(m.Member)
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, m.Score, b)
+	}
+	conn := z.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int64(conn.Do("ZADD", args...))
+}
+
+// IncrBy ZINCRBY 为 member 的 score 加上 incr, 返回新的 score.
+func (z *SortedSet) IncrBy(member interface{}, incr float64) (score float64, err error) {
+	b, err := z.codec.Marshal(member)
+	if err != nil {
+		return
+	}
+	conn := z.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Float64(conn.Do("ZINCRBY", z.key, incr, b))
+}
+
+// Score ZSCORE 返回 member 的 score, member 不存在时返回 ErrNil.
+func (z *SortedSet) Score(member interface{}) (score float64, err error) {
+	b, err := z.codec.Marshal(member)
+	if err != nil {
+		return
+	}
+	conn := z.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Float64(conn.Do("ZSCORE", z.key, b))
+}
+
+// Rank ZRANK 返回 member 按 score 从小到大的排名, member 不存在时返回 ErrNil.
+func (z *SortedSet) Rank(member interface{}) (rank int64, err error) {
+	return z.rank("ZRANK", member)
+}
+
+// RevRank ZREVRANK 返回 member 按 score 从大到小的排名, member 不存在时返回 ErrNil.
+func (z *SortedSet) RevRank(member interface{}) (rank int64, err error) {
+	return z.rank("ZREVRANK", member)
+}
+
+func (z *SortedSet) rank(cmd string, member interface{}) (rank int64, err error) {
+	b, err := z.codec.Marshal(member)
+	if err != nil {
+		return
+	}
+	conn := z.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int64(conn.Do(cmd, z.key, b))
+}
+
+// Remove ZREM 移除一个或多个 member, 返回移除的数量.
+func (z *SortedSet) Remove(members ...interface{}) (num int, err error) {
+	args, err := marshalAll(z.codec, redis.Args{z.key}, members)
+	if err != nil {
+		return
+	}
+	conn := z.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int(conn.Do("ZREM", args...))
+}
+
+// RemoveRangeByRank ZREMRANGEBYRANK 移除排名在 [start, stop] 区间的成员.
+func (z *SortedSet) RemoveRangeByRank(start, stop int64) (num int, err error) {
+	conn := z.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int(conn.Do("ZREMRANGEBYRANK", z.key, start, stop))
+}
+
+// RemoveRangeByScore ZREMRANGEBYSCORE 移除 score 在 [min, max] 区间的成员.
+func (z *SortedSet) RemoveRangeByScore(min, max interface{}) (num int, err error) {
+	conn := z.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int(conn.Do("ZREMRANGEBYSCORE", z.key, min, max))
+}
+
+// Card ZCARD 返回成员数量.
+func (z *SortedSet) Card() (num int64, err error) {
+	conn := z.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int64(conn.Do("ZCARD", z.key))
+}
+
+// Count ZCOUNT 返回 score 在 [min, max] 区间的成员数量.
+func (z *SortedSet) Count(min, max interface{}) (num int64, err error) {
+	conn := z.pool.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int64(conn.Do("ZCOUNT", z.key, min, max))
+}
+
+// Range ZRANGE 将区间内的成员解码到 dst, dst 为 *[]T.
+func (z *SortedSet) Range(opt *ZRangeOptions, dst interface{}) (err error) {
+	conn := z.pool.Pool.Get()
+	defer conn.Close()
+
+	values, err := redis.ByteSlices(conn.Do("ZRANGE", opt.args(redis.Args{z.key})...))
+	if err != nil {
+		return err
+	}
+	return decodeSlice(z.codec, values, dst)
+}
+
+// RangeWithScores ZRANGE WITHSCORES 将区间内的成员解码到 dst, dst 为 *[]T,
+// 返回与 dst 一一对应的 score.
+func (z *SortedSet) RangeWithScores(opt *ZRangeOptions, dst interface{}) (scores []float64, err error) {
+	conn := z.pool.Pool.Get()
+	defer conn.Close()
+
+	values, err := redis.ByteSlices(conn.Do("ZRANGE", append(opt.args(redis.Args{z.key}), "WITHSCORES")...))
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("redis: ZRANGE WITHSCORES expects even number of values")
+	}
+	members := make([][]byte, 0, len(values)/2)
+	scores = make([]float64, 0, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		score, err := redis.Float64(values[i+1], nil)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, values[i])
+		scores = append(scores, score)
+	}
+	if err = decodeSlice(z.codec, members, dst); err != nil {
+		return nil, err
+	}
+	return scores, nil
+}
+
+// Scan ZSCAN 迭代所有 member, Iterator.Decode 解码 member, Iterator.Score 为 score.
+func (z *SortedSet) Scan(ctx context.Context, opt *ScanOptions) *Iterator {
+	return newIterator(ctx, z.pool, "ZSCAN", z.key, opt, z.codec)
+}
@@ -17,6 +17,11 @@ type Viper struct {
 	onConfigChange func(fsnotify.Event)
 }
 
+// NewViper new a viper watching path, set OnConfigChange before WatchConfig.
+func NewViper(path string) *Viper {
+	return &Viper{path: path}
+}
+
 // LoadConfig load config
 func LoadConfig(path string, v interface{}) (err error) {
 	var j []byte
@@ -1,6 +1,6 @@
 module github.com/any-lyu/go.library
 
-go 1.12
+go 1.13
 
 require (
 	github.com/allegro/bigcache v1.2.1
@@ -11,7 +11,9 @@ require (
 	github.com/garyburd/redigo v1.6.0
 	github.com/go-sql-driver/mysql v1.4.1
 	github.com/go-xorm/xorm v0.7.6
+	github.com/golang/protobuf v1.3.2
 	github.com/json-iterator/go v1.1.7
+	github.com/modern-go/reflect2 v1.0.1
 	github.com/opentracing/opentracing-go v1.1.0
 	github.com/pkg/errors v0.8.1
 	github.com/prometheus/client_golang v1.1.0
@@ -28,5 +30,4 @@ require (
 	golang.org/x/text v0.3.2
 	golang.org/x/time v0.0.0-20190308202827-9d24e82272b4
 	xorm.io/core v0.7.0
-
 )
@@ -0,0 +1,121 @@
+package json
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+	"unsafe"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/modern-go/reflect2"
+
+	"github.com/any-lyu/go.library/errors"
+	xtime "github.com/any-lyu/go.library/time"
+)
+
+// Extension encodes and decodes the values of one type for codecs made by
+// New, overriding the type's default and its json.Marshaler methods.
+type Extension struct {
+	Type reflect.Type
+	// Marshal returns the json of v, a value of Type.
+	Marshal func(v interface{}) ([]byte, error)
+	// Unmarshal decodes data into v, a pointer to Type.
+	Unmarshal func(data []byte, v interface{}) error
+}
+
+var (
+	// TimeRFC3339 encodes xtime.Time as a RFC3339 string, decoding either
+	// a RFC3339 string or unix seconds.
+	TimeRFC3339 = &Extension{
+		Type: reflect.TypeOf(xtime.Time(0)),
+		Marshal: func(v interface{}) ([]byte, error) {
+			return []byte(strconv.Quote(v.(xtime.Time).Time().Format(time.RFC3339))), nil
+		},
+		Unmarshal: unmarshalTime,
+	}
+	// TimeUnix encodes xtime.Time as unix seconds, decoding either unix
+	// seconds or a RFC3339 string.
+	TimeUnix = &Extension{
+		Type: reflect.TypeOf(xtime.Time(0)),
+		Marshal: func(v interface{}) ([]byte, error) {
+			return strconv.AppendInt(nil, int64(v.(xtime.Time)), 10), nil
+		},
+		Unmarshal: unmarshalTime,
+	}
+)
+
+func unmarshalTime(data []byte, v interface{}) error {
+	t := v.(*xtime.Time)
+	if len(data) > 0 && data[0] == '"' {
+		s, err := strconv.Unquote(string(data))
+		if err != nil {
+			return err
+		}
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		*t = xtime.Time(tm.Unix())
+		return nil
+	}
+	if string(data) == "null" {
+		return nil
+	}
+	i, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return errors.Wrapf(err, "json: bad time %s", data)
+	}
+	*t = xtime.Time(i)
+	return nil
+}
+
+func (e *Extension) extension() jsoniter.Extension {
+	return &extension{ext: e}
+}
+
+type extension struct {
+	jsoniter.DummyExtension
+	ext *Extension
+}
+
+func (e *extension) CreateEncoder(typ reflect2.Type) jsoniter.ValEncoder {
+	if typ.Type1() == e.ext.Type && e.ext.Marshal != nil {
+		return &extensionCodec{ext: e.ext}
+	}
+	return nil
+}
+
+func (e *extension) CreateDecoder(typ reflect2.Type) jsoniter.ValDecoder {
+	if typ.Type1() == e.ext.Type && e.ext.Unmarshal != nil {
+		return &extensionCodec{ext: e.ext}
+	}
+	return nil
+}
+
+type extensionCodec struct {
+	ext *Extension
+}
+
+func (c *extensionCodec) IsEmpty(ptr unsafe.Pointer) bool {
+	v := reflect.NewAt(c.ext.Type, ptr).Elem()
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(c.ext.Type).Interface())
+}
+
+func (c *extensionCodec) Encode(ptr unsafe.Pointer, stream *jsoniter.Stream) {
+	b, err := c.ext.Marshal(reflect.NewAt(c.ext.Type, ptr).Elem().Interface())
+	if err != nil {
+		stream.Error = err
+		return
+	}
+	stream.Write(b)
+}
+
+func (c *extensionCodec) Decode(ptr unsafe.Pointer, iter *jsoniter.Iterator) {
+	data := iter.SkipAndReturnBytes()
+	if iter.Error != nil {
+		return
+	}
+	if err := c.ext.Unmarshal(data, reflect.NewAt(c.ext.Type, ptr).Interface()); err != nil {
+		iter.ReportError("decode "+c.ext.Type.String(), err.Error())
+	}
+}
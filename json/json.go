@@ -1,6 +1,7 @@
 package json
 
 import (
+	"encoding/json"
 	"io"
 
 	jsoniter "github.com/json-iterator/go"
@@ -27,3 +28,87 @@ func NewDecoder(reader io.Reader) *jsoniter.Decoder {
 func NewEncoder(writer io.Writer) *jsoniter.Encoder {
 	return jsonConfig.NewEncoder(writer)
 }
+
+// Encoder a streaming encoder of a codec.
+type Encoder interface {
+	Encode(v interface{}) error
+	SetIndent(prefix, indent string)
+	SetEscapeHTML(on bool)
+}
+
+// Decoder a streaming decoder of a codec.
+type Decoder interface {
+	Decode(v interface{}) error
+	More() bool
+	Buffered() io.Reader
+	UseNumber()
+	DisallowUnknownFields()
+}
+
+// Codec a json implementation, select one per use.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+var (
+	// Std is encoding/json.
+	Std Codec = stdCodec{}
+	// Compatible is jsoniter compatible with encoding/json, used by the
+	// package functions.
+	Compatible Codec = &iterCodec{api: jsonConfig}
+	// Fastest is jsoniter fastest mode, it does not escape html, sort map
+	// keys or keep float precision beyond 6 digits.
+	Fastest Codec = &iterCodec{api: jsoniter.ConfigFastest}
+)
+
+// New new a jsoniter codec of config with the extensions, e.g. for times:
+//
+//	codec := json.New(jsoniter.Config{EscapeHTML: true, SortMapKeys: true}, json.TimeRFC3339)
+func New(c jsoniter.Config, exts ...*Extension) Codec {
+	api := c.Froze()
+	for _, e := range exts {
+		api.RegisterExtension(e.extension())
+	}
+	return &iterCodec{api: api}
+}
+
+type iterCodec struct {
+	api jsoniter.API
+}
+
+func (c *iterCodec) Marshal(v interface{}) ([]byte, error) {
+	return c.api.Marshal(v)
+}
+
+func (c *iterCodec) Unmarshal(data []byte, v interface{}) error {
+	return c.api.Unmarshal(data, v)
+}
+
+func (c *iterCodec) NewEncoder(w io.Writer) Encoder {
+	return c.api.NewEncoder(w)
+}
+
+func (c *iterCodec) NewDecoder(r io.Reader) Decoder {
+	return c.api.NewDecoder(r)
+}
+
+type stdCodec struct{}
+
+func (stdCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (stdCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (stdCodec) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+func (stdCodec) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
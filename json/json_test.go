@@ -0,0 +1,95 @@
+package json
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+
+	xtime "github.com/any-lyu/go.library/time"
+)
+
+type order struct {
+	ID      int64      `json:"id"`
+	Name    string     `json:"name"`
+	Created xtime.Time `json:"created"`
+}
+
+func TestCodecs(t *testing.T) {
+	o := &order{ID: 1, Name: "<a>", Created: 1546300800}
+	for _, c := range []Codec{Std, Compatible, Fastest} {
+		b, err := c.Marshal(o)
+		assert.NoError(t, err)
+		var got order
+		assert.NoError(t, c.Unmarshal(b, &got))
+		assert.Equal(t, *o, got)
+	}
+	b, _ := Std.Marshal(o)
+	assert.Contains(t, string(b), `\u003ca\u003e`)
+	b, _ = Fastest.Marshal(o)
+	assert.Contains(t, string(b), `<a>`)
+}
+
+func TestExtension(t *testing.T) {
+	c := New(jsoniter.Config{EscapeHTML: true}, TimeRFC3339)
+	created := xtime.Time(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC).Unix())
+	b, err := c.Marshal(&order{ID: 1, Created: created})
+	assert.NoError(t, err)
+	want := `"created":"` + created.Time().Format(time.RFC3339) + `"`
+	assert.Contains(t, string(b), want)
+
+	var got order
+	assert.NoError(t, c.Unmarshal(b, &got))
+	assert.Equal(t, created, got.Created)
+	assert.NoError(t, c.Unmarshal([]byte(`{"created":1546300800}`), &got))
+	assert.Equal(t, created, got.Created)
+	assert.Error(t, c.Unmarshal([]byte(`{"created":"yesterday"}`), &got))
+
+	// other codecs are not affected
+	b, _ = Compatible.Marshal(&order{Created: created})
+	assert.Contains(t, string(b), `"created":1546300800`)
+	b, _ = New(jsoniter.Config{}, TimeUnix).Marshal(&order{Created: created})
+	assert.Contains(t, string(b), `"created":1546300800`)
+}
+
+func TestArrayDecoder(t *testing.T) {
+	doc := `{"meta":{"n":[1,2]},"data":{"total":3,"items":[{"id":1},{"id":2,"name":"b"},{"id":3}]}}`
+	for _, c := range []Codec{nil, Std, Fastest} {
+		d := NewArrayDecoder(c, strings.NewReader(doc), "data", "items")
+		var ids []int64
+		for d.Next() {
+			var o order
+			assert.NoError(t, d.Decode(&o))
+			ids = append(ids, o.ID)
+		}
+		assert.NoError(t, d.Err())
+		assert.Equal(t, []int64{1, 2, 3}, ids)
+	}
+
+	// undecoded elements are skipped
+	d := NewArrayDecoder(nil, strings.NewReader(`[{"id":1},[1,[2]],{"id":3}]`))
+	n := 0
+	for d.Next() {
+		n++
+	}
+	assert.NoError(t, d.Err())
+	assert.Equal(t, 3, n)
+
+	d = NewArrayDecoder(nil, strings.NewReader(`{"data":null}`), "data")
+	assert.False(t, d.Next())
+	assert.NoError(t, d.Err())
+
+	d = NewArrayDecoder(nil, strings.NewReader(`{"data":[]}`), "items")
+	assert.False(t, d.Next())
+	assert.Error(t, d.Err())
+
+	d = NewArrayDecoder(nil, strings.NewReader(`[{"id":1},{"id":`))
+	assert.True(t, d.Next())
+	assert.True(t, d.Next())
+	var o order
+	assert.Error(t, d.Decode(&o))
+	assert.False(t, d.Next())
+	assert.Error(t, d.Err())
+}
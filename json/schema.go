@@ -0,0 +1,492 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/any-lyu/go.library/errors"
+)
+
+// Schema a compiled JSON Schema, supporting the validation keywords of
+// draft 7 except dependencies, if/then/else and remote $ref:
+//
+//	s, err := json.CompileSchema(schema)
+//	...
+//	if err := s.Unmarshal(body, &req); err != nil {
+//		// err is a ValidationError listing every invalid field
+//	}
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 schemaTypes        `json:"type"`
+	Enum                 []interface{}      `json:"enum"`
+	Const                *interface{}       `json:"const"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+	MinProperties        *int               `json:"minProperties"`
+	MaxProperties        *int               `json:"maxProperties"`
+	Items                *Schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	UniqueItems          bool               `json:"uniqueItems"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+	Format               string             `json:"format"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum"`
+	MultipleOf           *float64           `json:"multipleOf"`
+	AllOf                []*Schema          `json:"allOf"`
+	AnyOf                []*Schema          `json:"anyOf"`
+	OneOf                []*Schema          `json:"oneOf"`
+	Not                  *Schema            `json:"not"`
+	Definitions          map[string]*Schema `json:"definitions"`
+	Defs                 map[string]*Schema `json:"$defs"`
+
+	boolean *bool // the true or false schema
+	pattern *regexp.Regexp
+	ref     *Schema
+}
+
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*t = schemaTypes{s}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// UnmarshalJSON implement json.Unmarshaler, accepting boolean schemas.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "true" || string(data) == "false" {
+		b := string(data) == "true"
+		s.boolean = &b
+		return nil
+	}
+	type schema Schema
+	return json.Unmarshal(data, (*schema)(s))
+}
+
+// CompileSchema compile a JSON Schema document.
+func CompileSchema(data []byte) (*Schema, error) {
+	s := new(Schema)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, errors.Wrap(err, "json: bad schema")
+	}
+	if err := s.compile(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Schema) compile(root *Schema) (err error) {
+	if s == nil {
+		return nil
+	}
+	if s.Pattern != "" {
+		if s.pattern, err = regexp.Compile(s.Pattern); err != nil {
+			return errors.Wrapf(err, "json: bad schema pattern %q", s.Pattern)
+		}
+	}
+	if s.Ref != "" {
+		if s.ref, err = root.resolve(s.Ref); err != nil {
+			return err
+		}
+	}
+	subs := []*Schema{s.AdditionalProperties, s.Items, s.Not}
+	subs = append(subs, s.AllOf...)
+	subs = append(subs, s.AnyOf...)
+	subs = append(subs, s.OneOf...)
+	for _, m := range []map[string]*Schema{s.Properties, s.Definitions, s.Defs} {
+		for _, sub := range m {
+			subs = append(subs, sub)
+		}
+	}
+	for _, sub := range subs {
+		if err = sub.compile(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) resolve(ref string) (*Schema, error) {
+	if ref == "#" {
+		return s, nil
+	}
+	parts := strings.Split(ref, "/")
+	if len(parts) == 3 && parts[0] == "#" {
+		var defs map[string]*Schema
+		switch parts[1] {
+		case "definitions":
+			defs = s.Definitions
+		case "$defs":
+			defs = s.Defs
+		}
+		if d, ok := defs[parts[2]]; ok {
+			return d, nil
+		}
+	}
+	return nil, errors.Errorf("json: unresolvable schema $ref %q", ref)
+}
+
+// SchemaError a value not valid against its schema.
+type SchemaError struct {
+	Path    string // JSON pointer of the value, "" for the document
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// ValidationError the schema errors of a document.
+type ValidationError []*SchemaError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e))
+	for i, se := range e {
+		msgs[i] = se.Error()
+	}
+	return "json: invalid document: " + strings.Join(msgs, "; ")
+}
+
+// Validate validates the json document data, returning a ValidationError
+// if it is not valid.
+func (s *Schema) Validate(data []byte) error {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return err
+	}
+	if errs := s.validate("", v, nil); len(errs) > 0 {
+		return ValidationError(errs)
+	}
+	return nil
+}
+
+// ValidateValue validates the json encoding of v.
+func (s *Schema) ValidateValue(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Validate(data)
+}
+
+// schemaConfig decodes case sensitively, so only the validated keys are
+// decoded into struct fields.
+var schemaConfig = jsoniter.Config{
+	EscapeHTML:             true,
+	SortMapKeys:            true,
+	ValidateJsonRawMessage: true,
+	CaseSensitive:          true,
+}.Froze()
+
+// Unmarshal validates data then unmarshals it into v, object keys are
+// matched to struct fields case sensitively.
+func (s *Schema) Unmarshal(data []byte, v interface{}) error {
+	if err := s.Validate(data); err != nil {
+		return err
+	}
+	return schemaConfig.Unmarshal(data, v)
+}
+
+func (s *Schema) validate(path string, v interface{}, errs []*SchemaError) []*SchemaError {
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, &SchemaError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.boolean != nil {
+		if !*s.boolean {
+			fail("not allowed")
+		}
+		return errs
+	}
+	if s.ref != nil {
+		return s.ref.validate(path, v, errs)
+	}
+	if len(s.Type) > 0 && !s.Type.match(v) {
+		fail("must be %s", strings.Join(s.Type, " or "))
+		return errs
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if equal(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of %s", mustMarshal(s.Enum))
+		}
+	}
+	if s.Const != nil && !equal(*s.Const, v) {
+		fail("must be %s", mustMarshal(*s.Const))
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		errs = s.validateObject(path, v, errs)
+	case []interface{}:
+		errs = s.validateArray(path, v, errs)
+	case string:
+		errs = s.validateString(path, v, errs)
+	case json.Number:
+		errs = s.validateNumber(path, v, errs)
+	}
+	for _, sub := range s.AllOf {
+		errs = sub.validate(path, v, errs)
+	}
+	if len(s.AnyOf) > 0 {
+		ok := false
+		for _, sub := range s.AnyOf {
+			if len(sub.validate(path, v, nil)) == 0 {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			fail("must match any of the schemas")
+		}
+	}
+	if len(s.OneOf) > 0 {
+		n := 0
+		for _, sub := range s.OneOf {
+			if len(sub.validate(path, v, nil)) == 0 {
+				n++
+			}
+		}
+		if n != 1 {
+			fail("must match exactly one of the schemas, matched %d", n)
+		}
+	}
+	if s.Not != nil && len(s.Not.validate(path, v, nil)) == 0 {
+		fail("must not match the schema")
+	}
+	return errs
+}
+
+func (t schemaTypes) match(v interface{}) bool {
+	for _, typ := range t {
+		switch v := v.(type) {
+		case nil:
+			if typ == "null" {
+				return true
+			}
+		case bool:
+			if typ == "boolean" {
+				return true
+			}
+		case string:
+			if typ == "string" {
+				return true
+			}
+		case json.Number:
+			if typ == "number" {
+				return true
+			}
+			if typ == "integer" {
+				f, err := v.Float64()
+				if err == nil && f == math.Trunc(f) {
+					return true
+				}
+			}
+		case []interface{}:
+			if typ == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if typ == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Schema) validateObject(path string, v map[string]interface{}, errs []*SchemaError) []*SchemaError {
+	for _, name := range s.Required {
+		if _, ok := v[name]; !ok {
+			errs = append(errs, &SchemaError{Path: path + "/" + escapePointer(name), Message: "is required"})
+		}
+	}
+	if s.MinProperties != nil && len(v) < *s.MinProperties {
+		errs = append(errs, &SchemaError{Path: path, Message: fmt.Sprintf("must have at least %d properties", *s.MinProperties)})
+	}
+	if s.MaxProperties != nil && len(v) > *s.MaxProperties {
+		errs = append(errs, &SchemaError{Path: path, Message: fmt.Sprintf("must have at most %d properties", *s.MaxProperties)})
+	}
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pv := v[name]
+		sub, ok := s.Properties[name]
+		if !ok {
+			sub = s.AdditionalProperties
+		}
+		if sub != nil {
+			errs = sub.validate(path+"/"+escapePointer(name), pv, errs)
+		}
+	}
+	return errs
+}
+
+func (s *Schema) validateArray(path string, v []interface{}, errs []*SchemaError) []*SchemaError {
+	if s.MinItems != nil && len(v) < *s.MinItems {
+		errs = append(errs, &SchemaError{Path: path, Message: fmt.Sprintf("must have at least %d items", *s.MinItems)})
+	}
+	if s.MaxItems != nil && len(v) > *s.MaxItems {
+		errs = append(errs, &SchemaError{Path: path, Message: fmt.Sprintf("must have at most %d items", *s.MaxItems)})
+	}
+	if s.UniqueItems {
+	unique:
+		for i := range v {
+			for j := i + 1; j < len(v); j++ {
+				if equal(v[i], v[j]) {
+					errs = append(errs, &SchemaError{Path: path, Message: fmt.Sprintf("items %d and %d are equal", i, j)})
+					break unique
+				}
+			}
+		}
+	}
+	if s.Items != nil {
+		for i, item := range v {
+			errs = s.Items.validate(path+"/"+strconv.Itoa(i), item, errs)
+		}
+	}
+	return errs
+}
+
+func (s *Schema) validateString(path string, v string, errs []*SchemaError) []*SchemaError {
+	n := utf8.RuneCountInString(v)
+	if s.MinLength != nil && n < *s.MinLength {
+		errs = append(errs, &SchemaError{Path: path, Message: fmt.Sprintf("must be at least %d characters", *s.MinLength)})
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		errs = append(errs, &SchemaError{Path: path, Message: fmt.Sprintf("must be at most %d characters", *s.MaxLength)})
+	}
+	if s.pattern != nil && !s.pattern.MatchString(v) {
+		errs = append(errs, &SchemaError{Path: path, Message: fmt.Sprintf("must match %q", s.Pattern)})
+	}
+	if s.Format != "" && !validFormat(s.Format, v) {
+		errs = append(errs, &SchemaError{Path: path, Message: "must be a valid " + s.Format})
+	}
+	return errs
+}
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// validFormat checks the common formats, unknown formats are valid.
+func validFormat(format, v string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, v)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", v)
+		return err == nil
+	case "email":
+		i := strings.LastIndexByte(v, '@')
+		return i > 0 && i < len(v)-1 && !strings.ContainsAny(v, " \t\r\n")
+	case "uri":
+		u, err := url.Parse(v)
+		return err == nil && u.Scheme != ""
+	case "uuid":
+		return uuidRegexp.MatchString(v)
+	case "ipv4":
+		ip := net.ParseIP(v)
+		return ip != nil && ip.To4() != nil && !strings.Contains(v, ":")
+	case "ipv6":
+		return net.ParseIP(v) != nil && strings.Contains(v, ":")
+	}
+	return true
+}
+
+func (s *Schema) validateNumber(path string, n json.Number, errs []*SchemaError) []*SchemaError {
+	v, err := n.Float64()
+	if err != nil {
+		return append(errs, &SchemaError{Path: path, Message: "must be a number"})
+	}
+	if s.Minimum != nil && v < *s.Minimum {
+		errs = append(errs, &SchemaError{Path: path, Message: fmt.Sprintf("must be >= %v", *s.Minimum)})
+	}
+	if s.Maximum != nil && v > *s.Maximum {
+		errs = append(errs, &SchemaError{Path: path, Message: fmt.Sprintf("must be <= %v", *s.Maximum)})
+	}
+	if s.ExclusiveMinimum != nil && v <= *s.ExclusiveMinimum {
+		errs = append(errs, &SchemaError{Path: path, Message: fmt.Sprintf("must be > %v", *s.ExclusiveMinimum)})
+	}
+	if s.ExclusiveMaximum != nil && v >= *s.ExclusiveMaximum {
+		errs = append(errs, &SchemaError{Path: path, Message: fmt.Sprintf("must be < %v", *s.ExclusiveMaximum)})
+	}
+	if s.MultipleOf != nil && *s.MultipleOf > 0 {
+		if q := v / *s.MultipleOf; q != math.Trunc(q) {
+			errs = append(errs, &SchemaError{Path: path, Message: fmt.Sprintf("must be a multiple of %v", *s.MultipleOf)})
+		}
+	}
+	return errs
+}
+
+// equal compares json values, numbers by value.
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = normalize(e)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = normalize(e)
+		}
+		return out
+	}
+	return v
+}
+
+func mustMarshal(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func escapePointer(s string) string {
+	return strings.Replace(strings.Replace(s, "~", "~0", -1), "/", "~1", -1)
+}
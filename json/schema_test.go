@@ -0,0 +1,88 @@
+package json
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const orderSchema = `{
+  "type": "object",
+  "required": ["id", "items"],
+  "additionalProperties": false,
+  "properties": {
+    "id": {"type": "integer", "minimum": 1},
+    "email": {"type": "string", "format": "email"},
+    "status": {"enum": ["new", "paid"]},
+    "note": {"type": ["string", "null"], "maxLength": 5},
+    "items": {"type": "array", "minItems": 1, "uniqueItems": true, "items": {"$ref": "#/definitions/item"}}
+  },
+  "definitions": {
+    "item": {
+      "type": "object",
+      "required": ["sku"],
+      "properties": {
+        "sku": {"type": "string", "pattern": "^[A-Z]{3}-[0-9]+$"},
+        "qty": {"type": "number", "exclusiveMinimum": 0, "multipleOf": 0.5}
+      }
+    }
+  }
+}`
+
+func TestSchema(t *testing.T) {
+	s, err := CompileSchema([]byte(orderSchema))
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Validate([]byte(`{"id":1,"status":"new","note":null,"items":[{"sku":"ABC-1","qty":1.5}]}`)))
+
+	err = s.Validate([]byte(`{"id":1.5,"email":"x","status":"gone","note":"too long","extra":1,
+		"items":[{"sku":"abc","qty":0},{"qty":0.3}]}`))
+	verr, ok := err.(ValidationError)
+	assert.True(t, ok)
+	var paths []string
+	for _, e := range verr {
+		paths = append(paths, e.Path)
+	}
+	assert.Equal(t, []string{
+		"/email", "/extra", "/id", "/items/0/qty", "/items/0/sku", "/items/1/sku", "/items/1/qty", "/note", "/status",
+	}, paths)
+
+	err = s.Validate([]byte(`{"items":[{"sku":"ABC-1"},{"sku":"ABC-1"}]}`))
+	assert.Equal(t, "json: invalid document: /id: is required; /items: items 0 and 1 are equal", err.Error())
+
+	var o struct {
+		ID int64 `json:"id"`
+	}
+	assert.Error(t, s.Unmarshal([]byte(`{"id":0,"items":[{"sku":"ABC-1"}]}`), &o))
+	assert.NoError(t, s.Unmarshal([]byte(`{"id":7,"items":[{"sku":"ABC-1"}]}`), &o))
+	assert.Equal(t, int64(7), o.ID)
+
+	assert.NoError(t, s.ValidateValue(map[string]interface{}{"id": 2, "items": []map[string]string{{"sku": "XYZ-9"}}}))
+
+	// keys differing in case are not validated, so they must not be decoded
+	s, err = CompileSchema([]byte(`{"properties": {"amount": {"maximum": 100}}}`))
+	assert.NoError(t, err)
+	var p struct {
+		Amount int `json:"amount"`
+	}
+	assert.NoError(t, s.Unmarshal([]byte(`{"amount":1,"AMOUNT":999999}`), &p))
+	assert.Equal(t, 1, p.Amount)
+}
+
+func TestSchemaCombinators(t *testing.T) {
+	s, err := CompileSchema([]byte(`{
+		"oneOf": [{"type": "string"}, {"type": "integer"}, {"type": "number", "minimum": 10}],
+		"not": {"const": "x"}
+	}`))
+	assert.NoError(t, err)
+	assert.NoError(t, s.Validate([]byte(`"a"`)))
+	assert.NoError(t, s.Validate([]byte(`1`)))
+	assert.Error(t, s.Validate([]byte(`12`)))
+	assert.Error(t, s.Validate([]byte(`"x"`)))
+	assert.Error(t, s.Validate([]byte(`true`)))
+
+	_, err = CompileSchema([]byte(`{"$ref": "#/definitions/missing"}`))
+	assert.Error(t, err)
+	_, err = CompileSchema([]byte(`{"pattern": "("}`))
+	assert.Error(t, err)
+}
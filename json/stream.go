@@ -0,0 +1,138 @@
+package json
+
+import (
+	"io"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/any-lyu/go.library/errors"
+)
+
+// ArrayDecoder decodes a json array element by element without reading it
+// whole, for large payloads:
+//
+//	d := json.NewArrayDecoder(nil, r, "data", "items")
+//	for d.Next() {
+//		var it Item
+//		if err := d.Decode(&it); err != nil {
+//			return err
+//		}
+//	}
+//	return d.Err()
+type ArrayDecoder struct {
+	codec   Codec
+	iter    *jsoniter.Iterator
+	path    []string
+	started bool
+	pending bool // an element is read by Next but not decoded
+	done    bool
+	err     error
+}
+
+// NewArrayDecoder new an array decoder of the array found by following the
+// object keys path from the top value of r, elements are decoded by codec,
+// Compatible if nil.
+func NewArrayDecoder(codec Codec, r io.Reader, path ...string) *ArrayDecoder {
+	if codec == nil {
+		codec = Compatible
+	}
+	api := jsonConfig
+	if c, ok := codec.(*iterCodec); ok {
+		api = c.api
+	}
+	return &ArrayDecoder{codec: codec, iter: jsoniter.Parse(api, r, 4096), path: path}
+}
+
+// Next reads up to the next element, returning false at the end of the
+// array or on error.
+func (d *ArrayDecoder) Next() bool {
+	if d.done {
+		return false
+	}
+	more := false
+	if !d.started {
+		d.started = true
+		if !d.seek() {
+			return d.stop()
+		}
+		more = d.iter.ReadArray()
+	} else {
+		if d.pending {
+			d.iter.Skip()
+		}
+		more = d.iter.ReadArray()
+	}
+	if !more || d.iter.Error != nil {
+		return d.stop()
+	}
+	d.pending = true
+	return true
+}
+
+func (d *ArrayDecoder) seek() bool {
+	for _, key := range d.path {
+		if d.iter.WhatIsNext() != jsoniter.ObjectValue {
+			d.err = errors.Errorf("json: %q is not in an object", key)
+			return false
+		}
+		for {
+			field := d.iter.ReadObject()
+			if d.iter.Error != nil {
+				return false
+			}
+			if field == "" {
+				d.err = errors.Errorf("json: key %q not found", key)
+				return false
+			}
+			if field == key {
+				break
+			}
+			d.iter.Skip()
+		}
+	}
+	switch d.iter.WhatIsNext() {
+	case jsoniter.ArrayValue:
+		return true
+	case jsoniter.NilValue:
+		d.iter.Skip()
+		return false
+	}
+	if d.iter.Error == nil {
+		d.err = errors.New("json: value is not an array")
+	}
+	return false
+}
+
+func (d *ArrayDecoder) stop() bool {
+	d.done, d.pending = true, false
+	if d.err == nil && d.iter.Error != nil && d.iter.Error != io.EOF {
+		d.err = d.iter.Error
+	}
+	return false
+}
+
+// Decode decodes the element read by Next into v.
+func (d *ArrayDecoder) Decode(v interface{}) error {
+	if !d.pending {
+		return errors.New("json: Decode without Next")
+	}
+	d.pending = false
+	if _, ok := d.codec.(*iterCodec); ok {
+		d.iter.ReadVal(v)
+	} else if data := d.iter.SkipAndReturnBytes(); d.iter.Error == nil {
+		if err := d.codec.Unmarshal(data, v); err != nil {
+			return err
+		}
+	}
+	if d.iter.Error != nil && d.iter.Error != io.EOF {
+		d.err = d.iter.Error
+		d.done = true
+		return d.err
+	}
+	return nil
+}
+
+// Err returns the error stopping Next.
+func (d *ArrayDecoder) Err() error {
+	return d.err
+}
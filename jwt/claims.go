@@ -0,0 +1,76 @@
+package jwt
+
+import (
+	"encoding/json"
+)
+
+// Claims is implemented by every claims struct signed or verified by Signer
+// and Verifier. Embed StandardClaims to get it:
+//
+//	type OrderClaims struct {
+//		OrderID int64    `json:"order_id"`
+//		Scopes  []string `json:"scopes"`
+//		jwt.StandardClaims
+//	}
+type Claims interface {
+	Valid() error
+	Standard() *StandardClaims
+}
+
+// StandardClaims the registered claims of RFC 7519.
+type StandardClaims struct {
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+}
+
+// Valid implement jwt-go Claims, the time and audience checks are done by
+// Verifier with its clock skew.
+func (c *StandardClaims) Valid() error {
+	return nil
+}
+
+// Standard implement Claims.
+func (c *StandardClaims) Standard() *StandardClaims {
+	return c
+}
+
+// Audience the aud claim, a single string or an array of strings.
+type Audience []string
+
+// Contains reports whether aud is in the audience.
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON encodes a single audience as a string.
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON accepts a string or an array of strings.
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+	var ss []string
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return err
+	}
+	*a = ss
+	return nil
+}
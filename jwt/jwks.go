@@ -0,0 +1,184 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/any-lyu/go.library/errors"
+	log "github.com/any-lyu/go.library/logs"
+)
+
+// JWK a public JSON web key, RFC 7517.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Use       string `json:"use,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JWKS a JSON web key set document.
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// JWKS returns the public keys able to verify now, including the ones
+// scheduled to sign later so verifiers learn them before rotation. HMAC
+// secrets are never published.
+func (s *KeySet) JWKS() *JWKS {
+	now := time.Now()
+	doc := &JWKS{Keys: []*JWK{}}
+	for _, k := range s.Keys() {
+		if !k.canVerify(now) {
+			continue
+		}
+		if jwk := newJWK(k); jwk != nil {
+			doc.Keys = append(doc.Keys, jwk)
+		}
+	}
+	return doc
+}
+
+// JWKSHandler serves the JWKS document of keys, typically mounted at
+// /.well-known/jwks.json.
+func JWKSHandler(keys *KeySet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		b, err := json.Marshal(keys.JWKS())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.Write(b)
+	})
+}
+
+var b64 = base64.RawURLEncoding
+
+func newJWK(k *Key) *JWK {
+	jwk := &JWK{KeyID: k.ID, Algorithm: k.Algorithm, Use: "sig"}
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = b64.EncodeToString(pub.N.Bytes())
+		jwk.E = b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = pub.Curve.Params().Name
+		jwk.X = b64.EncodeToString(pad(pub.X.Bytes(), size))
+		jwk.Y = b64.EncodeToString(pad(pub.Y.Bytes(), size))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = b64.EncodeToString(pub)
+	default:
+		return nil
+	}
+	return jwk
+}
+
+func pad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	p := make([]byte, size)
+	copy(p[size-len(b):], b)
+	return p
+}
+
+// Key parses the JWK into a verification only Key.
+func (j *JWK) Key() (*Key, error) {
+	k := &Key{ID: j.KeyID, Algorithm: j.Algorithm}
+	switch j.KeyType {
+	case "RSA":
+		n, err := b64.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		k.Public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if k.Algorithm == "" {
+			k.Algorithm = "RS256"
+		}
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Curve {
+		case "P-256":
+			curve, k.Algorithm = elliptic.P256(), orDefault(k.Algorithm, "ES256")
+		case "P-384":
+			curve, k.Algorithm = elliptic.P384(), orDefault(k.Algorithm, "ES384")
+		case "P-521":
+			curve, k.Algorithm = elliptic.P521(), orDefault(k.Algorithm, "ES512")
+		default:
+			return nil, errors.Errorf("jwt: jwk %q unsupported curve %q", j.KeyID, j.Curve)
+		}
+		x, err := b64.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64.DecodeString(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		k.Public = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	case "OKP":
+		if j.Curve != "Ed25519" {
+			return nil, errors.Errorf("jwt: jwk %q unsupported curve %q", j.KeyID, j.Curve)
+		}
+		x, err := b64.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		k.Public, k.Algorithm = ed25519.PublicKey(x), SigningMethodEdDSA.Alg()
+	default:
+		return nil, errors.Errorf("jwt: jwk %q unsupported key type %q", j.KeyID, j.KeyType)
+	}
+	return k, k.fix()
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// keys parses the usable signature keys of the document.
+func (d *JWKS) keys() []*Key {
+	keys := make([]*Key, 0, len(d.Keys))
+	for _, j := range d.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		k, err := j.Key()
+		if err != nil {
+			log.Warn("jwt: skip jwk: %v", err)
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,122 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/any-lyu/go.library/errors"
+	xtime "github.com/any-lyu/go.library/time"
+)
+
+func TestJWKSHandler(t *testing.T) {
+	keys, err := NewKeySet(testKeys(t)...)
+	assert.NoError(t, err)
+	srv := httptest.NewServer(JWKSHandler(keys))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	var doc JWKS
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+
+	kids := map[string]string{}
+	for _, j := range doc.Keys {
+		kids[j.KeyID] = j.KeyType
+	}
+	// the HMAC secret is not published
+	assert.Equal(t, map[string]string{"rs": "RSA", "ps": "RSA", "es": "EC", "ed": "OKP"}, kids)
+
+	// round trip
+	for _, k := range doc.keys() {
+		for _, orig := range keys.Keys() {
+			if orig.ID == k.ID {
+				assert.Equal(t, orig.Public, k.Public)
+				assert.Equal(t, orig.Algorithm, k.Algorithm)
+			}
+		}
+	}
+
+	resp, err = http.Post(srv.URL, "application/json", nil)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestRemoteVerifier(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	issuer, err := NewKeySet(&Key{ID: "k1", Algorithm: "ES384", Private: ecKey})
+	assert.NoError(t, err)
+	signer := &Signer{conf: &Config{Issuer: "auth"}, keys: issuer, now: time.Now}
+
+	var fetches int32
+	jwks := JWKSHandler(issuer)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		jwks.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	remote, err := NewRemoteKeySet(&RemoteConfig{URL: srv.URL, MinRefetch: xtime.Duration(time.Nanosecond)})
+	assert.NoError(t, err)
+	defer remote.Close()
+	v := NewRemoteVerifier(&Config{Issuer: "auth"}, remote)
+
+	token, err := signer.Sign(&TokenGenerateClaims{UID: "1"})
+	assert.NoError(t, err)
+	var claims TokenGenerateClaims
+	assert.NoError(t, v.Verify(token, &claims))
+	assert.Equal(t, "1", claims.UID)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	// the issuer rotates, the unknown kid triggers a refetch
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, issuer.Rotate(&Key{ID: "k2", Algorithm: "EdDSA", Private: edKey}, time.Hour))
+	token, err = signer.Sign(&TokenGenerateClaims{UID: "2"})
+	assert.NoError(t, err)
+	assert.NoError(t, v.Verify(token, &claims))
+	assert.Equal(t, "2", claims.UID)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+
+	// a forged kid does not refetch within MinRefetch
+	remote.conf.MinRefetch = xtime.Duration(time.Hour)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	forged, _ := NewKeySet(&Key{ID: "k3", Algorithm: "RS256", Private: rsaKey})
+	token, err = (&Signer{conf: &Config{}, keys: forged, now: time.Now}).Sign(&StandardClaims{Issuer: "auth"})
+	assert.NoError(t, err)
+	assert.Equal(t, errors.ErrToken, errors.Cause(v.Verify(token, &StandardClaims{})))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+}
+
+func TestRemoteKeySetRefresh(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	issuer, _ := NewKeySet(&Key{ID: "k1", Algorithm: "EdDSA", Private: edKey})
+	srv := httptest.NewServer(JWKSHandler(issuer))
+	defer srv.Close()
+
+	remote, err := NewRemoteKeySet(&RemoteConfig{URL: srv.URL, Refresh: xtime.Duration(10 * time.Millisecond)})
+	assert.NoError(t, err)
+	defer remote.Close()
+	assert.Len(t, remote.Keys().Keys(), 1)
+
+	_, edKey2, _ := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, issuer.Add(&Key{ID: "k2", Algorithm: "EdDSA", Private: edKey2}))
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, remote.Keys().Keys(), 2)
+
+	srv.Close()
+	_, err = NewRemoteKeySet(&RemoteConfig{URL: srv.URL})
+	assert.Error(t, err)
+}
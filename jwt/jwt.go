@@ -1,10 +1,9 @@
 package jwt
 
 import (
+	"context"
 	"strings"
-	"time"
-
-	"github.com/dgrijalva/jwt-go"
+	"sync"
 
 	"github.com/any-lyu/go.library/errors"
 )
@@ -12,42 +11,71 @@ import (
 // TokenGenerateClaims jwt token add uid
 type TokenGenerateClaims struct {
 	UID string `json:"uid"`
-	jwt.StandardClaims
+	StandardClaims
+}
+
+var (
+	_mu sync.RWMutex
+	// NOTE nil until Init, TokenGenerate and TokenParse fail closed without
+	// keys.
+	_signer *Signer
+	_store  RevocationStore
+
+	errNotInit = errors.New("jwt: keys not configured, call Init")
+)
+
+// Init init the signer and verifier used by TokenGenerate and TokenParse,
+// also can reload config after first time call.
+func Init(c *Config) error {
+	signer, err := NewSigner(c)
+	if err != nil {
+		return err
+	}
+	_mu.Lock()
+	_signer = signer
+	_mu.Unlock()
+	return nil
+}
+
+// InitRevocation sets the revocation store checked by TokenParse, nil
+// disables the check.
+func InitRevocation(store RevocationStore) {
+	_mu.Lock()
+	_store = store
+	_mu.Unlock()
 }
 
-var hmacSampleSecret = []byte("124")
+func defaults() (*Signer, RevocationStore) {
+	_mu.RLock()
+	defer _mu.RUnlock()
+	return _signer, _store
+}
 
-// TokenGenerate jwt generate token
+// TokenGenerate jwt generate token, Init must be called first.
 func TokenGenerate(uid string) (tokenString string, err error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, TokenGenerateClaims{
-		UID: uid,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Duration(120) * time.Hour).Unix(),
-			Issuer:    "system",
-		},
-	})
-	// Sign and get the complete encoded token as a string using the secret
-	return token.SignedString(hmacSampleSecret)
+	signer, _ := defaults()
+	if signer == nil {
+		return "", errNotInit
+	}
+	return signer.Sign(&TokenGenerateClaims{UID: uid, StandardClaims: StandardClaims{Subject: uid}})
 }
 
-// TokenParse jwt parse token
+// TokenParse jwt parse token, every token is rejected until Init is called.
 func TokenParse(tokenString string) (token *TokenGenerateClaims, err error) {
 	if !strings.HasPrefix(tokenString, "Bearer ") {
 		return nil, errors.Wrapf(errors.ErrToken, "TokenParse:%s", "token invalid")
 	}
 	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
 	token = new(TokenGenerateClaims)
-	claims, err := jwt.ParseWithClaims(tokenString, token, func(token *jwt.Token) (i interface{}, e error) {
-		return hmacSampleSecret, nil
-	})
-	if err != nil {
-		return nil, errors.Wrapf(errors.ErrToken, "TokenParse:%s", err.Error())
+	signer, store := defaults()
+	if signer == nil {
+		return nil, errors.Wrap(errors.ErrToken, errNotInit.Error())
 	}
-	if token, ok := claims.Claims.(*TokenGenerateClaims); ok && claims.Valid {
-		return token, nil
+	if err = signer.Verifier().Verify(tokenString, token); err == nil {
+		err = checkRevoked(context.Background(), store, &token.StandardClaims)
 	}
-	if !claims.Valid {
-		return nil, errors.Wrapf(errors.ErrToken, "TokenParse:%s", "valid")
+	if err != nil {
+		return nil, errors.Wrap(err, "TokenParse")
 	}
-	return nil, errors.Wrapf(errors.ErrToken, "TokenParse")
+	return token, nil
 }
@@ -0,0 +1,279 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/any-lyu/go.library/errors"
+)
+
+// Key is a signing or verification key, ID is used as the kid header.
+type Key struct {
+	ID        string
+	Algorithm string // HS256/384/512, RS*, PS*, ES*, EdDSA
+	// Private is []byte for HMAC, *rsa.PrivateKey, *ecdsa.PrivateKey or
+	// ed25519.PrivateKey, nil for a verification only key.
+	Private interface{}
+	// Public is []byte for HMAC, *rsa.PublicKey, *ecdsa.PublicKey or
+	// ed25519.PublicKey, derived from Private when nil.
+	Public interface{}
+	// ActiveAt the key signs new tokens from ActiveAt, zero means at once.
+	ActiveAt time.Time
+	// RetireAt the key neither signs nor verifies from RetireAt, zero means
+	// never.
+	RetireAt time.Time
+}
+
+func (k *Key) fix() error {
+	if jwt.GetSigningMethod(k.Algorithm) == nil {
+		return errors.Errorf("jwt: key %q unknown algorithm %q", k.ID, k.Algorithm)
+	}
+	if k.Public == nil {
+		switch priv := k.Private.(type) {
+		case []byte:
+			k.Public = priv
+		case *rsa.PrivateKey:
+			k.Public = &priv.PublicKey
+		case *ecdsa.PrivateKey:
+			k.Public = &priv.PublicKey
+		case ed25519.PrivateKey:
+			k.Public = priv.Public()
+		}
+	}
+	var ok bool
+	switch {
+	case strings.HasPrefix(k.Algorithm, "HS"):
+		_, ok = k.Public.([]byte)
+	case strings.HasPrefix(k.Algorithm, "RS"), strings.HasPrefix(k.Algorithm, "PS"):
+		_, ok = k.Public.(*rsa.PublicKey)
+	case strings.HasPrefix(k.Algorithm, "ES"):
+		_, ok = k.Public.(*ecdsa.PublicKey)
+	case k.Algorithm == SigningMethodEdDSA.Alg():
+		_, ok = k.Public.(ed25519.PublicKey)
+	}
+	if !ok {
+		return errors.Errorf("jwt: key %q type %T does not match algorithm %s", k.ID, k.Public, k.Algorithm)
+	}
+	return nil
+}
+
+func (k *Key) canSign(now time.Time) bool {
+	return k.Private != nil && !now.Before(k.ActiveAt) && k.canVerify(now)
+}
+
+func (k *Key) canVerify(now time.Time) bool {
+	return k.RetireAt.IsZero() || now.Before(k.RetireAt)
+}
+
+var errUnknownKey = errors.New("jwt: unknown key")
+
+// KeySet is the set of keys of a Signer or Verifier, it can be changed at
+// runtime to rotate keys.
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []*Key
+}
+
+// NewKeySet new a key set.
+func NewKeySet(keys ...*Key) (*KeySet, error) {
+	s := &KeySet{}
+	for _, k := range keys {
+		if err := s.Add(k); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add adds a key, replacing the key with the same ID.
+func (s *KeySet) Add(k *Key) error {
+	if err := k.fix(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, old := range s.keys {
+		if old.ID == k.ID {
+			s.keys[i] = k
+			return nil
+		}
+	}
+	s.keys = append(s.keys, k)
+	return nil
+}
+
+// Remove removes the key with id.
+func (s *KeySet) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, k := range s.keys {
+		if k.ID == id {
+			s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// Rotate schedules next as the signing key from next.ActiveAt (now when
+// zero), the keys signing before keep verifying for grace after that, which
+// should be at least the token lifetime.
+func (s *KeySet) Rotate(next *Key, grace time.Duration) error {
+	if next.ActiveAt.IsZero() {
+		next.ActiveAt = time.Now()
+	}
+	if err := next.fix(); err != nil {
+		return err
+	}
+	retire := next.ActiveAt.Add(grace)
+	s.mu.Lock()
+	for i, k := range s.keys {
+		if k.ID != next.ID && k.Private != nil && (k.RetireAt.IsZero() || k.RetireAt.After(retire)) {
+			// NOTE copy on write, the old key may be in use by a snapshot.
+			retired := *k
+			retired.RetireAt = retire
+			s.keys[i] = &retired
+		}
+	}
+	s.mu.Unlock()
+	return s.Add(next)
+}
+
+func (s *KeySet) replace(keys []*Key) {
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+}
+
+// Keys returns a snapshot of the keys.
+func (s *KeySet) Keys() []*Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]*Key, len(s.keys))
+	copy(keys, s.keys)
+	return keys
+}
+
+// signing returns the most recently activated key able to sign at now.
+func (s *KeySet) signing(now time.Time) (*Key, error) {
+	keys := s.Keys()
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].ActiveAt.After(keys[j].ActiveAt) })
+	for _, k := range keys {
+		if k.canSign(now) {
+			return k, nil
+		}
+	}
+	return nil, errors.New("jwt: no active signing key")
+}
+
+// verifying returns the key for the kid and alg of a token, a token without
+// kid is checked against the first key of its algorithm.
+func (s *KeySet) verifying(kid, alg string, now time.Time) (*Key, error) {
+	for _, k := range s.Keys() {
+		if kid != "" && k.ID != kid {
+			continue
+		}
+		if k.Algorithm != alg {
+			if kid != "" {
+				return nil, errors.Errorf("jwt: key %q algorithm %s, token %s", kid, k.Algorithm, alg)
+			}
+			continue
+		}
+		if !k.canVerify(now) {
+			return nil, errors.Errorf("jwt: key %q retired", k.ID)
+		}
+		return k, nil
+	}
+	return nil, errors.Wrapf(errUnknownKey, "kid %q", kid)
+}
+
+// KeyConfig a key in config, PEM keys are given inline or as a file.
+type KeyConfig struct {
+	ID             string    `json:"id"`
+	Algorithm      string    `json:"alg"`
+	Secret         string    `json:"secret"`
+	PrivateKey     string    `json:"private_key"`
+	PrivateKeyFile string    `json:"private_key_file"`
+	PublicKey      string    `json:"public_key"`
+	PublicKeyFile  string    `json:"public_key_file"`
+	ActiveAt       time.Time `json:"active_at"`
+	RetireAt       time.Time `json:"retire_at"`
+}
+
+// Key parses the config into a Key.
+func (c *KeyConfig) Key() (k *Key, err error) {
+	k = &Key{ID: c.ID, Algorithm: c.Algorithm, ActiveAt: c.ActiveAt, RetireAt: c.RetireAt}
+	if strings.HasPrefix(c.Algorithm, "HS") {
+		if c.Secret == "" {
+			return nil, errors.Errorf("jwt: key %q empty secret", c.ID)
+		}
+		k.Private = []byte(c.Secret)
+		return k, k.fix()
+	}
+	priv, err := readPEM(c.PrivateKey, c.PrivateKeyFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "jwt: key %q", c.ID)
+	}
+	pub, err := readPEM(c.PublicKey, c.PublicKeyFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "jwt: key %q", c.ID)
+	}
+	if priv != nil {
+		if k.Private, err = parsePrivateKey(c.Algorithm, priv); err != nil {
+			return nil, errors.Wrapf(err, "jwt: key %q", c.ID)
+		}
+	}
+	if pub != nil {
+		if k.Public, err = parsePublicKey(c.Algorithm, pub); err != nil {
+			return nil, errors.Wrapf(err, "jwt: key %q", c.ID)
+		}
+	}
+	return k, k.fix()
+}
+
+func readPEM(inline, file string) ([]byte, error) {
+	if inline != "" {
+		return []byte(inline), nil
+	}
+	if file != "" {
+		return ioutil.ReadFile(file)
+	}
+	return nil, nil
+}
+
+func parsePrivateKey(alg string, b []byte) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		return jwt.ParseRSAPrivateKeyFromPEM(b)
+	case strings.HasPrefix(alg, "ES"):
+		return jwt.ParseECPrivateKeyFromPEM(b)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("invalid PEM")
+	}
+	return x509.ParsePKCS8PrivateKey(block.Bytes)
+}
+
+func parsePublicKey(alg string, b []byte) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		return jwt.ParseRSAPublicKeyFromPEM(b)
+	case strings.HasPrefix(alg, "ES"):
+		return jwt.ParseECPublicKeyFromPEM(b)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("invalid PEM")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
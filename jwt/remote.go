@@ -0,0 +1,147 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/any-lyu/go.library/errors"
+	log "github.com/any-lyu/go.library/logs"
+	xtime "github.com/any-lyu/go.library/time"
+)
+
+// RemoteConfig remote JWKS config.
+type RemoteConfig struct {
+	URL     string         `json:"url"`
+	Refresh xtime.Duration `json:"refresh"` // background refresh interval, default 1h
+	// MinRefetch is the minimum interval between refetches triggered by an
+	// unknown kid, so forged kids can not flood the issuer, default 10s.
+	MinRefetch xtime.Duration `json:"min_refetch"`
+	Timeout    xtime.Duration `json:"timeout"` // fetch timeout, default 5s
+}
+
+func (c *RemoteConfig) fix() {
+	if c.Refresh <= 0 {
+		c.Refresh = xtime.Duration(time.Hour)
+	}
+	if c.MinRefetch <= 0 {
+		c.MinRefetch = xtime.Duration(10 * time.Second)
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = xtime.Duration(5 * time.Second)
+	}
+}
+
+// RemoteKeySet keeps a KeySet in sync with the JWKS document of an issuer.
+type RemoteKeySet struct {
+	conf   *RemoteConfig
+	client *http.Client
+	keys   *KeySet
+
+	mu        sync.Mutex
+	lastFetch time.Time
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewRemoteKeySet fetches the JWKS document once and refreshes it in
+// background until Close.
+func NewRemoteKeySet(c *RemoteConfig) (*RemoteKeySet, error) {
+	c.fix()
+	r := &RemoteKeySet{
+		conf:    c,
+		client:  &http.Client{Timeout: time.Duration(c.Timeout)},
+		keys:    &KeySet{},
+		closing: make(chan struct{}),
+	}
+	if err := r.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+	go r.refreshproc()
+	return r, nil
+}
+
+// Keys returns the key set mirroring the remote document.
+func (r *RemoteKeySet) Keys() *KeySet {
+	return r.keys
+}
+
+// Close stops the background refresh.
+func (r *RemoteKeySet) Close() {
+	r.closeOnce.Do(func() { close(r.closing) })
+}
+
+// Refresh fetches the JWKS document and replaces the keys.
+func (r *RemoteKeySet) Refresh(ctx context.Context) error {
+	r.mu.Lock()
+	r.lastFetch = time.Now()
+	r.mu.Unlock()
+	return r.fetch(ctx)
+}
+
+func (r *RemoteKeySet) fetch(ctx context.Context) error {
+	req, err := http.NewRequest(http.MethodGet, r.conf.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "jwt: fetch jwks %s", r.conf.URL)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("jwt: fetch jwks %s status %d", r.conf.URL, resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrapf(err, "jwt: fetch jwks %s", r.conf.URL)
+	}
+	doc := new(JWKS)
+	if err = json.Unmarshal(body, doc); err != nil {
+		return errors.Wrapf(err, "jwt: decode jwks %s", r.conf.URL)
+	}
+	r.keys.replace(doc.keys())
+	return nil
+}
+
+// refetch refreshes for an unknown kid unless the last fetch is more recent
+// than MinRefetch, it reports whether the keys were refreshed.
+func (r *RemoteKeySet) refetch() bool {
+	r.mu.Lock()
+	if time.Since(r.lastFetch) < time.Duration(r.conf.MinRefetch) {
+		r.mu.Unlock()
+		return false
+	}
+	r.lastFetch = time.Now()
+	r.mu.Unlock()
+	if err := r.fetch(context.Background()); err != nil {
+		log.Error("jwt: refetch jwks error(%v)", err)
+		return false
+	}
+	return true
+}
+
+func (r *RemoteKeySet) refreshproc() {
+	ticker := time.NewTicker(time.Duration(r.conf.Refresh))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.closing:
+			return
+		case <-ticker.C:
+			if err := r.Refresh(context.Background()); err != nil {
+				// NOTE keep the last known keys.
+				log.Error("jwt: refresh jwks error(%v)", err)
+			}
+		}
+	}
+}
+
+// NewRemoteVerifier new a verifier checking tokens against the keys of a
+// remote issuer, c gives the expected issuer, audience and leeway.
+func NewRemoteVerifier(c *Config, remote *RemoteKeySet) *Verifier {
+	return &Verifier{conf: c, keys: remote.keys, remote: remote, now: time.Now}
+}
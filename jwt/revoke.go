@@ -0,0 +1,108 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	redigo "github.com/garyburd/redigo/redis"
+
+	"github.com/any-lyu/go.library/cache/redis"
+)
+
+// RevocationStore records revoked token ids (jti) and refresh sessions
+// until they would have expired anyway.
+type RevocationStore interface {
+	// Revoke revokes id until the given time, it reports whether id was
+	// already revoked, atomically.
+	Revoke(ctx context.Context, id string, until time.Time) (existed bool, err error)
+	// Revoked reports whether id is revoked.
+	Revoked(ctx context.Context, id string) (bool, error)
+}
+
+// NewMemoryStore new a process local revocation store.
+func NewMemoryStore() RevocationStore {
+	return &memoryStore{ids: make(map[string]time.Time)}
+}
+
+type memoryStore struct {
+	mu    sync.Mutex
+	ids   map[string]time.Time
+	sweep time.Time
+}
+
+func (s *memoryStore) Revoke(_ context.Context, id string, until time.Time) (bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.sweep) > time.Minute {
+		for k, t := range s.ids {
+			if !now.Before(t) {
+				delete(s.ids, k)
+			}
+		}
+		s.sweep = now
+	}
+	if t, ok := s.ids[id]; ok && now.Before(t) {
+		return true, nil
+	}
+	s.ids[id] = until
+	return false, nil
+}
+
+func (s *memoryStore) Revoked(_ context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	t, ok := s.ids[id]
+	s.mu.Unlock()
+	return ok && time.Now().Before(t), nil
+}
+
+// NewRedisStore new a revocation store shared by all instances on
+// cache/redis, keys are prefix+id, default prefix "jwt:revoked:".
+func NewRedisStore(client *redis.Client, prefix string) RevocationStore {
+	if prefix == "" {
+		prefix = "jwt:revoked:"
+	}
+	return &redisStore{client: client, prefix: prefix}
+}
+
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func (s *redisStore) Revoke(ctx context.Context, id string, until time.Time) (bool, error) {
+	ttl := time.Until(until) / time.Millisecond
+	if ttl <= 0 {
+		ttl = 1
+	}
+	conn, err := s.client.Pool.GetContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	_, err = redigo.String(conn.Do("SET", s.prefix+id, 1, "PX", int64(ttl), "NX"))
+	if err == redigo.ErrNil {
+		return true, nil
+	}
+	return false, err
+}
+
+func (s *redisStore) Revoked(ctx context.Context, id string) (bool, error) {
+	conn, err := s.client.Pool.GetContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	return redigo.Bool(conn.Do("EXISTS", s.prefix+id))
+}
+
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
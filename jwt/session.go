@@ -0,0 +1,160 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"github.com/any-lyu/go.library/errors"
+	log "github.com/any-lyu/go.library/logs"
+)
+
+const typeRefresh = "refresh"
+
+// TokenPair an access token and the refresh token to renew it.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // access token lifetime in seconds
+}
+
+type refreshClaims struct {
+	Family string `json:"fid"` // the session all rotated refresh tokens belong to
+	StandardClaims
+}
+
+// Sessions issues access/refresh token pairs.
+//
+// A refresh token can be used once, Refresh rotates it to a new pair of the
+// same session. Using a refresh token twice means it leaked, so the whole
+// session is revoked and both holders have to sign in again.
+type Sessions struct {
+	signer   *Signer
+	store    RevocationStore
+	lifetime time.Duration
+}
+
+// NewSessions new a sessions issuer, refresh tokens expire after lifetime,
+// never if lifetime is 0. A nil store defaults to NewMemoryStore, which
+// only suits a single process.
+func NewSessions(signer *Signer, store RevocationStore, lifetime time.Duration) *Sessions {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &Sessions{signer: signer, store: store, lifetime: lifetime}
+}
+
+// Issue issues a pair for claims in a new session.
+func (s *Sessions) Issue(ctx context.Context, claims Claims) (*TokenPair, error) {
+	return s.issue(claims, newID())
+}
+
+func (s *Sessions) issue(claims Claims, family string) (*TokenPair, error) {
+	access, err := s.signer.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+	std := claims.Standard()
+	refresh, err := s.signer.sign(&refreshClaims{
+		Family:         family,
+		StandardClaims: StandardClaims{Subject: std.Subject},
+	}, typeRefresh, s.lifetime)
+	if err != nil {
+		return nil, err
+	}
+	pair := &TokenPair{AccessToken: access, RefreshToken: refresh}
+	if std.ExpiresAt != 0 {
+		pair.ExpiresIn = std.ExpiresAt - std.IssuedAt
+	}
+	return pair, nil
+}
+
+// Refresh uses refresh to issue a new pair of its session, load returns the
+// access claims for the subject of the session.
+func (s *Sessions) Refresh(ctx context.Context, refresh string, load func(subject string) (Claims, error)) (*TokenPair, error) {
+	rc := new(refreshClaims)
+	if err := s.signer.Verifier().verify(refresh, rc, typeRefresh); err != nil {
+		return nil, err
+	}
+	revoked, err := s.store.Revoked(ctx, rc.Family)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errors.Wrapf(errors.ErrToken, "jwt: session %s revoked", rc.Family)
+	}
+	used, err := s.store.Revoke(ctx, rc.ID, s.until(rc.ExpiresAt))
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		log.Warn("jwt: refresh token %s of session %s subject %s reused, revoke session", rc.ID, rc.Family, rc.Subject)
+		if _, err = s.store.Revoke(ctx, rc.Family, s.sessionUntil()); err != nil {
+			return nil, err
+		}
+		return nil, errors.Wrapf(errors.ErrToken, "jwt: refresh token %s reused", rc.ID)
+	}
+	claims, err := load(rc.Subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(claims, rc.Family)
+}
+
+// Verify verifies an access token like Verifier.Verify and checks it is not
+// revoked.
+func (s *Sessions) Verify(ctx context.Context, access string, claims Claims) error {
+	if err := s.signer.Verifier().Verify(access, claims); err != nil {
+		return err
+	}
+	return checkRevoked(ctx, s.store, claims.Standard())
+}
+
+// Revoke revokes the access token claims were verified from.
+func (s *Sessions) Revoke(ctx context.Context, claims Claims) error {
+	std := claims.Standard()
+	_, err := s.store.Revoke(ctx, std.ID, s.until(std.ExpiresAt))
+	return err
+}
+
+// until is how long a token expiring at exp stays revoked.
+func (s *Sessions) until(exp int64) time.Time {
+	if exp == 0 {
+		// NOTE a token without exp is revoked for good.
+		return s.signer.now().AddDate(100, 0, 0)
+	}
+	return time.Unix(exp, 0)
+}
+
+// sessionUntil is how long a session stays revoked, for good if refresh
+// tokens do not expire.
+func (s *Sessions) sessionUntil() time.Time {
+	if s.lifetime <= 0 {
+		return s.until(0)
+	}
+	return s.signer.now().Add(s.lifetime)
+}
+
+// Logout revokes the session of a refresh token, its access tokens stay valid
+// until they expire.
+func (s *Sessions) Logout(ctx context.Context, refresh string) error {
+	rc := new(refreshClaims)
+	if err := s.signer.Verifier().verify(refresh, rc, typeRefresh); err != nil {
+		return err
+	}
+	_, err := s.store.Revoke(ctx, rc.Family, s.sessionUntil())
+	return err
+}
+
+func checkRevoked(ctx context.Context, store RevocationStore, std *StandardClaims) error {
+	if store == nil || std.ID == "" {
+		return nil
+	}
+	revoked, err := store.Revoked(ctx, std.ID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errors.Wrapf(errors.ErrToken, "jwt: token %s revoked", std.ID)
+	}
+	return nil
+}
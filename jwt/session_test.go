@@ -0,0 +1,145 @@
+package jwt
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/any-lyu/go.library/cache/redis"
+	"github.com/any-lyu/go.library/cache/redis/redistest"
+	"github.com/any-lyu/go.library/errors"
+	xtime "github.com/any-lyu/go.library/time"
+)
+
+func newTestSessions(t *testing.T, store RevocationStore, lifetime time.Duration) *Sessions {
+	s, err := NewSigner(&Config{
+		Expire: xtime.Duration(time.Minute),
+		Keys:   []*KeyConfig{{ID: "k1", Algorithm: "HS256", Secret: "secret"}},
+	})
+	assert.NoError(t, err)
+	return NewSessions(s, store, lifetime)
+}
+
+func load(subject string) (Claims, error) {
+	return &TokenGenerateClaims{UID: subject, StandardClaims: StandardClaims{Subject: subject}}, nil
+}
+
+func testSessions(t *testing.T, store RevocationStore, lifetime time.Duration) {
+	ctx := context.Background()
+	s := newTestSessions(t, store, lifetime)
+
+	pair, err := s.Issue(ctx, &TokenGenerateClaims{UID: "u1", StandardClaims: StandardClaims{Subject: "u1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60), pair.ExpiresIn)
+
+	var claims TokenGenerateClaims
+	assert.NoError(t, s.Verify(ctx, pair.AccessToken, &claims))
+	assert.Equal(t, "u1", claims.UID)
+	// a refresh token is not an access token and the other way round
+	assert.Error(t, s.Verify(ctx, pair.RefreshToken, &TokenGenerateClaims{}))
+	_, err = s.Refresh(ctx, pair.AccessToken, load)
+	assert.Error(t, err)
+
+	next, err := s.Refresh(ctx, pair.RefreshToken, load)
+	assert.NoError(t, err)
+	assert.NotEqual(t, pair.RefreshToken, next.RefreshToken)
+	assert.NoError(t, s.Verify(ctx, next.AccessToken, &TokenGenerateClaims{}))
+
+	// replay of the rotated token revokes the whole session
+	_, err = s.Refresh(ctx, pair.RefreshToken, load)
+	assert.Equal(t, errors.ErrToken, errors.Cause(err))
+	_, err = s.Refresh(ctx, next.RefreshToken, load)
+	assert.Equal(t, errors.ErrToken, errors.Cause(err))
+
+	// revoke an access token
+	assert.NoError(t, s.Revoke(ctx, &claims))
+	err = s.Verify(ctx, pair.AccessToken, &TokenGenerateClaims{})
+	assert.Equal(t, errors.ErrToken, errors.Cause(err))
+
+	// logout
+	other, err := s.Issue(ctx, &TokenGenerateClaims{UID: "u2"})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Logout(ctx, other.RefreshToken))
+	_, err = s.Refresh(ctx, other.RefreshToken, load)
+	assert.Error(t, err)
+}
+
+func TestSessionsMemory(t *testing.T) {
+	testSessions(t, NewMemoryStore(), 24*time.Hour)
+	// refresh tokens without exp are revoked for good
+	testSessions(t, NewMemoryStore(), 0)
+}
+
+func TestSessionsNilStore(t *testing.T) {
+	testSessions(t, nil, time.Hour)
+}
+
+func TestSessionsRedis(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		keys = map[string]bool{}
+	)
+	srv := redistest.NewServer(func(cmd string, args []string) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case cmd == "SET" && len(args) == 5 && args[2] == "PX" && args[4] == "NX":
+			if !strings.HasPrefix(args[0], "jwt:revoked:") {
+				return redistest.Error("ERR bad key")
+			}
+			if keys[args[0]] {
+				return nil
+			}
+			keys[args[0]] = true
+			return redistest.Status("OK")
+		case cmd == "EXISTS" && len(args) == 1:
+			if keys[args[0]] {
+				return 1
+			}
+			return 0
+		}
+		return redistest.Error("ERR unexpected command")
+	})
+	defer srv.Close()
+	testSessions(t, NewRedisStore(&redis.Client{Pool: srv.Pool()}, ""), 24*time.Hour)
+}
+
+func TestTokenParseRevoked(t *testing.T) {
+	_, err := TokenParse("Bearer x")
+	assert.Equal(t, errors.ErrToken, errors.Cause(err), "fails closed before Init")
+
+	assert.NoError(t, Init(&Config{Expire: xtime.Duration(time.Hour), Keys: []*KeyConfig{{Algorithm: "HS256", Secret: "secret"}}}))
+	store := NewMemoryStore()
+	InitRevocation(store)
+	defer InitRevocation(nil)
+
+	token, err := TokenGenerate("u1")
+	assert.NoError(t, err)
+	claims, err := TokenParse("Bearer " + token)
+	assert.NoError(t, err)
+	assert.Equal(t, "u1", claims.Subject)
+
+	_, err = store.Revoke(context.Background(), claims.ID, time.Unix(claims.ExpiresAt, 0))
+	assert.NoError(t, err)
+	_, err = TokenParse("Bearer " + token)
+	assert.Equal(t, errors.ErrToken, errors.Cause(err))
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	existed, err := store.Revoke(ctx, "a", time.Now().Add(-time.Second))
+	assert.NoError(t, err)
+	assert.False(t, existed)
+	revoked, _ := store.Revoked(ctx, "a")
+	assert.False(t, revoked)
+
+	existed, _ = store.Revoke(ctx, "b", time.Now().Add(time.Hour))
+	assert.False(t, existed)
+	existed, _ = store.Revoke(ctx, "b", time.Now().Add(time.Hour))
+	assert.True(t, existed)
+}
@@ -0,0 +1,188 @@
+package jwt
+
+import (
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/any-lyu/go.library/errors"
+	xtime "github.com/any-lyu/go.library/time"
+)
+
+// DefaultExpire is the lifetime of signed tokens if Config.Expire is 0.
+const DefaultExpire = 120 * time.Hour
+
+// Config signer and verifier config.
+type Config struct {
+	Issuer   string         `json:"issuer"`   // iss of signed tokens, checked when verifying if not empty
+	Audience []string       `json:"audience"` // aud of signed tokens, verified tokens must have one of them if not empty
+	Expire   xtime.Duration `json:"expire"`   // lifetime of signed tokens, default DefaultExpire, negative means no exp
+	Leeway   xtime.Duration `json:"leeway"`   // allowed clock skew when checking exp, nbf and iat
+	Keys     []*KeyConfig   `json:"keys"`
+}
+
+func (c *Config) fix() {
+	if c.Expire == 0 {
+		c.Expire = xtime.Duration(DefaultExpire)
+	}
+}
+
+func (c *Config) keySet() (*KeySet, error) {
+	keys := make([]*Key, 0, len(c.Keys))
+	for _, kc := range c.Keys {
+		k, err := kc.Key()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return NewKeySet(keys...)
+}
+
+// Signer signs tokens with the active key of its KeySet.
+type Signer struct {
+	conf *Config
+	keys *KeySet
+	now  func() time.Time
+}
+
+// NewSigner new a signer from config.
+func NewSigner(c *Config) (*Signer, error) {
+	c.fix()
+	keys, err := c.keySet()
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{conf: c, keys: keys, now: time.Now}, nil
+}
+
+// Keys returns the key set, used to rotate keys at runtime.
+func (s *Signer) Keys() *KeySet {
+	return s.keys
+}
+
+// Verifier returns a verifier sharing the config and keys of the signer.
+func (s *Signer) Verifier() *Verifier {
+	return &Verifier{conf: s.conf, keys: s.keys, now: s.now}
+}
+
+// Sign signs claims, filling iss, aud, iat, exp and jti from config when
+// they are zero.
+func (s *Signer) Sign(claims Claims) (string, error) {
+	return s.sign(claims, "", time.Duration(s.conf.Expire))
+}
+
+func (s *Signer) sign(claims Claims, typ string, expire time.Duration) (string, error) {
+	now := s.now()
+	key, err := s.keys.signing(now)
+	if err != nil {
+		return "", err
+	}
+	std := claims.Standard()
+	if std.Issuer == "" {
+		std.Issuer = s.conf.Issuer
+	}
+	if len(std.Audience) == 0 {
+		std.Audience = s.conf.Audience
+	}
+	if std.IssuedAt == 0 {
+		std.IssuedAt = now.Unix()
+	}
+	if std.ExpiresAt == 0 && expire > 0 {
+		std.ExpiresAt = now.Add(expire).Unix()
+	}
+	if std.ID == "" {
+		std.ID = newID()
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	if typ != "" {
+		token.Header["typ"] = typ
+	}
+	return token.SignedString(key.Private)
+}
+
+// Verifier verifies tokens against the keys of its KeySet.
+type Verifier struct {
+	conf   *Config
+	keys   *KeySet
+	remote *RemoteKeySet
+	now    func() time.Time
+}
+
+// NewVerifier new a verifier from config, keys may be verification only.
+func NewVerifier(c *Config) (*Verifier, error) {
+	keys, err := c.keySet()
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{conf: c, keys: keys, now: time.Now}, nil
+}
+
+// Keys returns the key set, used to rotate keys at runtime.
+func (v *Verifier) Keys() *KeySet {
+	return v.keys
+}
+
+// Verify verifies the signature of token and decodes it into claims, then
+// checks exp, nbf, iat, iss and aud. Refresh tokens are rejected. The
+// returned error causes errors.ErrToken.
+func (v *Verifier) Verify(token string, claims Claims) error {
+	return v.verify(token, claims, "")
+}
+
+func (v *Verifier) verify(token string, claims Claims, typ string) error {
+	now := v.now()
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if got, _ := t.Header["typ"].(string); got != typ && (typ != "" || got == typeRefresh) {
+			return nil, errors.Errorf("unexpected token type %q", got)
+		}
+		kid, _ := t.Header["kid"].(string)
+		key, err := v.keys.verifying(kid, t.Method.Alg(), now)
+		if errors.Cause(err) == errUnknownKey && v.remote != nil && v.remote.refetch() {
+			key, err = v.keys.verifying(kid, t.Method.Alg(), now)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return key.Public, nil
+	})
+	if err != nil {
+		return errors.Wrapf(errors.ErrToken, "jwt: %s", err.Error())
+	}
+	if err = v.validate(claims.Standard(), now); err == nil {
+		err = claims.Valid()
+	}
+	if err != nil {
+		return errors.Wrapf(errors.ErrToken, "jwt: %s", err.Error())
+	}
+	return nil
+}
+
+func (v *Verifier) validate(c *StandardClaims, now time.Time) error {
+	leeway := time.Duration(v.conf.Leeway)
+	if c.ExpiresAt != 0 && !now.Before(time.Unix(c.ExpiresAt, 0).Add(leeway)) {
+		return errors.New("token is expired")
+	}
+	if c.NotBefore != 0 && now.Add(leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return errors.New("token is not valid yet")
+	}
+	if c.IssuedAt != 0 && now.Add(leeway).Before(time.Unix(c.IssuedAt, 0)) {
+		return errors.New("token used before issued")
+	}
+	if v.conf.Issuer != "" && c.Issuer != v.conf.Issuer {
+		return errors.Errorf("invalid issuer %q", c.Issuer)
+	}
+	if len(v.conf.Audience) > 0 {
+		for _, aud := range v.conf.Audience {
+			if c.Audience.Contains(aud) {
+				return nil
+			}
+		}
+		return errors.Errorf("invalid audience %v", []string(c.Audience))
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/any-lyu/go.library/errors"
+	xtime "github.com/any-lyu/go.library/time"
+)
+
+type orderClaims struct {
+	OrderID int64    `json:"order_id"`
+	Scopes  []string `json:"scopes"`
+	StandardClaims
+}
+
+func testKeys(t *testing.T) []*Key {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	return []*Key{
+		{ID: "hs", Algorithm: "HS256", Private: []byte("secret")},
+		{ID: "rs", Algorithm: "RS256", Private: rsaKey},
+		{ID: "ps", Algorithm: "PS384", Private: rsaKey},
+		{ID: "es", Algorithm: "ES256", Private: ecKey},
+		{ID: "ed", Algorithm: "EdDSA", Private: edKey},
+	}
+}
+
+func TestSignVerifyAlgorithms(t *testing.T) {
+	conf := &Config{Issuer: "order", Audience: []string{"api"}, Expire: xtime.Duration(time.Hour)}
+	for _, key := range testKeys(t) {
+		keys, err := NewKeySet(key)
+		assert.NoError(t, err)
+		s := &Signer{conf: conf, keys: keys, now: time.Now}
+
+		token, err := s.Sign(&orderClaims{OrderID: 7, Scopes: []string{"read"}})
+		assert.NoError(t, err, key.Algorithm)
+
+		var got orderClaims
+		assert.NoError(t, s.Verifier().Verify(token, &got), key.Algorithm)
+		assert.Equal(t, int64(7), got.OrderID)
+		assert.Equal(t, []string{"read"}, got.Scopes)
+		assert.Equal(t, "order", got.Issuer)
+		assert.Equal(t, Audience{"api"}, got.Audience)
+		assert.True(t, got.ExpiresAt > time.Now().Unix())
+	}
+}
+
+func TestVerifyClaims(t *testing.T) {
+	keys, err := NewKeySet(&Key{ID: "k1", Algorithm: "HS256", Private: []byte("secret")})
+	assert.NoError(t, err)
+	now := time.Unix(1500000000, 0)
+	s := &Signer{
+		conf: &Config{Issuer: "iss", Audience: []string{"a", "b"}, Leeway: xtime.Duration(30 * time.Second)},
+		keys: keys,
+		now:  func() time.Time { return now },
+	}
+	v := s.Verifier()
+	sign := func(c *StandardClaims) string {
+		token, err := s.Sign(c)
+		assert.NoError(t, err)
+		return token
+	}
+
+	// within leeway
+	assert.NoError(t, v.Verify(sign(&StandardClaims{ExpiresAt: now.Unix() - 10}), &StandardClaims{}))
+	assert.NoError(t, v.Verify(sign(&StandardClaims{NotBefore: now.Unix() + 10}), &StandardClaims{}))
+	assert.NoError(t, v.Verify(sign(&StandardClaims{Audience: Audience{"x", "b"}}), &StandardClaims{}))
+
+	for _, c := range []*StandardClaims{
+		{ExpiresAt: now.Unix() - 60},
+		{NotBefore: now.Unix() + 60},
+		{IssuedAt: now.Unix() + 60},
+		{Issuer: "other"},
+		{Audience: Audience{"x"}},
+	} {
+		err := v.Verify(sign(c), &StandardClaims{})
+		assert.Equal(t, errors.ErrToken, errors.Cause(err), "%+v", c)
+	}
+
+	// tampered signature
+	token := sign(&StandardClaims{})
+	err = v.Verify(token[:len(token)-2]+"xx", &StandardClaims{})
+	assert.Equal(t, errors.ErrToken, errors.Cause(err))
+}
+
+func TestKeyRotation(t *testing.T) {
+	keys, err := NewKeySet(&Key{ID: "old", Algorithm: "HS256", Private: []byte("old")})
+	assert.NoError(t, err)
+	now := time.Now()
+	s := &Signer{conf: &Config{}, keys: keys, now: func() time.Time { return now }}
+
+	oldToken, err := s.Sign(&StandardClaims{})
+	assert.NoError(t, err)
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	next := &Key{ID: "new", Algorithm: "EdDSA", Private: edKey, ActiveAt: now.Add(time.Minute)}
+	assert.NoError(t, keys.Rotate(next, time.Hour))
+
+	// scheduled, old key still signs
+	token, err := s.Sign(&StandardClaims{})
+	assert.NoError(t, err)
+	assert.Equal(t, oldToken[:20], token[:20])
+
+	now = now.Add(2 * time.Minute)
+	newToken, err := s.Sign(&StandardClaims{})
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldToken[:20], newToken[:20])
+	assert.NoError(t, s.Verifier().Verify(oldToken, &StandardClaims{}))
+	assert.NoError(t, s.Verifier().Verify(newToken, &StandardClaims{}))
+
+	// after grace the old key is retired
+	now = now.Add(time.Hour)
+	assert.Error(t, s.Verifier().Verify(oldToken, &StandardClaims{}))
+	assert.NoError(t, s.Verifier().Verify(newToken, &StandardClaims{}))
+
+	keys.Remove("new")
+	_, err = s.Sign(&StandardClaims{})
+	assert.Error(t, err)
+}
+
+func TestKeyAlgorithmMismatch(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	_, err = NewKeySet(&Key{ID: "k", Algorithm: "ES256", Private: rsaKey})
+	assert.Error(t, err)
+
+	// a token claiming HS256 with a RSA kid must not verify
+	keys, err := NewKeySet(&Key{ID: "rs", Algorithm: "RS256", Private: rsaKey})
+	assert.NoError(t, err)
+	hs, err := NewKeySet(&Key{ID: "rs", Algorithm: "HS256", Private: []byte("x")})
+	assert.NoError(t, err)
+	forged, err := (&Signer{conf: &Config{}, keys: hs, now: time.Now}).Sign(&StandardClaims{})
+	assert.NoError(t, err)
+	assert.Error(t, (&Verifier{conf: &Config{}, keys: keys, now: time.Now}).Verify(forged, &StandardClaims{}))
+}
+
+func TestConfigExpire(t *testing.T) {
+	for _, tt := range []struct {
+		expire xtime.Duration
+		exp    bool
+	}{
+		{0, true},
+		{xtime.Duration(time.Minute), true},
+		{-1, false},
+	} {
+		s, err := NewSigner(&Config{Expire: tt.expire, Keys: []*KeyConfig{{Algorithm: "HS256", Secret: "secret"}}})
+		assert.NoError(t, err)
+		token, err := s.Sign(&StandardClaims{})
+		assert.NoError(t, err)
+		var claims StandardClaims
+		assert.NoError(t, s.Verifier().Verify(token, &claims))
+		assert.Equal(t, tt.exp, claims.ExpiresAt != 0, "expire %v", tt.expire)
+	}
+	s, _ := NewSigner(&Config{Keys: []*KeyConfig{{Algorithm: "HS256", Secret: "secret"}}})
+	token, _ := s.Sign(&StandardClaims{})
+	var claims StandardClaims
+	assert.NoError(t, s.Verifier().Verify(token, &claims))
+	assert.Equal(t, int64(DefaultExpire/time.Second), claims.ExpiresAt-claims.IssuedAt, "tokens expire by default")
+}
+
+func TestConfig(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(ecKey)
+	assert.NoError(t, err)
+	privPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	der, err = x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	assert.NoError(t, err)
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	raw, _ := json.Marshal(map[string]interface{}{
+		"issuer": "svc",
+		"expire": "10m",
+		"keys":   []map[string]string{{"id": "es1", "alg": "ES256", "private_key": string(privPEM)}},
+	})
+	var conf Config
+	assert.NoError(t, json.Unmarshal(raw, &conf))
+	assert.Equal(t, xtime.Duration(10*time.Minute), conf.Expire)
+	s, err := NewSigner(&conf)
+	assert.NoError(t, err)
+	token, err := s.Sign(&TokenGenerateClaims{UID: "1"})
+	assert.NoError(t, err)
+
+	v, err := NewVerifier(&Config{Issuer: "svc", Keys: []*KeyConfig{{ID: "es1", Algorithm: "ES256", PublicKey: string(pubPEM)}}})
+	assert.NoError(t, err)
+	var claims TokenGenerateClaims
+	assert.NoError(t, v.Verify(token, &claims))
+	assert.Equal(t, "1", claims.UID)
+
+	assert.NoError(t, Init(&conf))
+	token, err = TokenGenerate("2")
+	assert.NoError(t, err)
+	parsed, err := TokenParse("Bearer " + token)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", parsed.UID)
+}
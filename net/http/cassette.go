@@ -0,0 +1,255 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/any-lyu/go.library/errors"
+)
+
+// Interaction is a request and its response, or error, saved in a
+// cassette.
+type Interaction struct {
+	Request  *RecordedRequest  `json:"request"`
+	Response *RecordedResponse `json:"response,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// RecordedRequest a recorded request.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   *Body       `json:"body,omitempty"`
+}
+
+// RecordedResponse a recorded response.
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   *Body       `json:"body,omitempty"`
+}
+
+// Body is saved as text, or base64 if it is not utf-8.
+type Body struct {
+	Text   string `json:"text,omitempty"`
+	Base64 string `json:"base64,omitempty"`
+}
+
+func newBody(b []byte) *Body {
+	switch {
+	case len(b) == 0:
+		return nil
+	case utf8.Valid(b):
+		return &Body{Text: string(b)}
+	}
+	return &Body{Base64: base64.StdEncoding.EncodeToString(b)}
+}
+
+// Bytes returns the body, nil for a nil body.
+func (b *Body) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	if b.Base64 != "" {
+		data, _ := base64.StdEncoding.DecodeString(b.Base64)
+		return data
+	}
+	return []byte(b.Text)
+}
+
+// Cassette is a file of interactions.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// LoadCassette loads the cassette saved at path.
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := new(Cassette)
+	if err = json.Unmarshal(b, c); err != nil {
+		return nil, errors.Wrapf(err, "http: cassette %s", path)
+	}
+	return c, nil
+}
+
+// Save saves the cassette at path as indented json, creating its directory.
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// DefaultRedactHeaders are the headers saved as *** by a Recorder.
+var DefaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Signature", "X-Api-Key"}
+
+// Recorder is a RoundTripper recording the interactions of Transport, to
+// be saved as a cassette replayed by a Replayer in tests.
+//
+//	rec := &http.Recorder{Transport: http.DefaultTransport}
+//	c := http.NewClient(&http.Config{Transport: rec})
+//	// ... call the live service with c
+//	err := rec.Save("testdata/orders.json")
+type Recorder struct {
+	Transport http.RoundTripper
+	// RedactHeaders are saved as ***, default DefaultRedactHeaders.
+	RedactHeaders []string
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// RoundTrip sends req by Transport and records it.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	sent := req.Clone(req.Context())
+	setRequestBody(sent, body)
+	in := &Interaction{Request: &RecordedRequest{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: r.redact(req.Header),
+		Body:   newBody(body),
+	}}
+	resp, err := r.Transport.RoundTrip(sent)
+	if err != nil {
+		in.Error = err.Error()
+		r.add(in)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	in.Response = &RecordedResponse{Status: resp.StatusCode, Header: r.redact(resp.Header), Body: newBody(b)}
+	r.add(in)
+	return resp, nil
+}
+
+func (r *Recorder) add(in *Interaction) {
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.mu.Unlock()
+}
+
+func (r *Recorder) redact(h http.Header) http.Header {
+	names := r.RedactHeaders
+	if names == nil {
+		names = DefaultRedactHeaders
+	}
+	h = h.Clone()
+	for _, name := range names {
+		if _, ok := h[http.CanonicalHeaderKey(name)]; ok {
+			h.Set(name, "***")
+		}
+	}
+	return h
+}
+
+// Save saves the interactions recorded so far at path.
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cassette.Save(path)
+}
+
+// Replayer is a RoundTripper answering requests by the interactions of a
+// cassette, without network. Each interaction answers one request, so a
+// request sent twice gets the responses recorded in order.
+type Replayer struct {
+	// Match reports whether in answers req of body, default same method,
+	// url and body.
+	Match func(req *http.Request, body []byte, in *Interaction) bool
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewReplayer new a replayer of the cassette saved at path.
+func NewReplayer(path string) (*Replayer, error) {
+	c, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Replayer{cassette: c, used: make([]bool, len(c.Interactions))}, nil
+}
+
+// RoundTrip answers req by the first unused matching interaction.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	match := r.Match
+	if match == nil {
+		match = matchInteraction
+	}
+	r.mu.Lock()
+	var in *Interaction
+	for i, c := range r.cassette.Interactions {
+		if !r.used[i] && match(req, body, c) {
+			r.used[i], in = true, c
+			break
+		}
+	}
+	r.mu.Unlock()
+	if in == nil {
+		return nil, errors.Errorf("http: no recorded interaction for %s %s", req.Method, req.URL)
+	}
+	if in.Response == nil {
+		return nil, errors.New(in.Error)
+	}
+	b := in.Response.Body.Bytes()
+	return &http.Response{
+		Status:        strconv.Itoa(in.Response.Status) + " " + http.StatusText(in.Response.Status),
+		StatusCode:    in.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        in.Response.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       req,
+	}, nil
+}
+
+// Unused returns the interactions not replayed, a test can check that all
+// the calls recorded were made.
+func (r *Replayer) Unused() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []*Interaction
+	for i, in := range r.cassette.Interactions {
+		if !r.used[i] {
+			unused = append(unused, in)
+		}
+	}
+	return unused
+}
+
+func matchInteraction(req *http.Request, body []byte, in *Interaction) bool {
+	return strings.EqualFold(req.Method, in.Request.Method) && req.URL.String() == in.Request.URL &&
+		bytes.Equal(body, in.Request.Body.Bytes())
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordReplay(t *testing.T) {
+	n := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "sid=1")
+		if len(b) == 0 {
+			b = []byte(`""`)
+		}
+		w.Write([]byte(`{"id":` + string(rune('0'+n)) + `,"name":` + string(b) + `}`))
+	}))
+	dir, err := ioutil.TempDir("", "cassette")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "testdata", "orders.json")
+
+	rec := &Recorder{Transport: http.DefaultTransport}
+	c := NewClient(&Config{Transport: rec, Headers: map[string]string{"Authorization": "Bearer secret"}, Stat: new(fakeStat)})
+	var o order
+	assert.NoError(t, c.PostJSONInto(context.Background(), srv.URL+"/orders", "a", &o))
+	assert.Equal(t, order{ID: 1, Name: "a"}, o)
+	assert.NoError(t, c.GetInto(context.Background(), srv.URL+"/orders", &o))
+	assert.NoError(t, c.GetInto(context.Background(), srv.URL+"/orders", &o))
+	assert.NoError(t, rec.Save(path))
+	srv.Close()
+
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "Bearer secret")
+	assert.NotContains(t, string(b), "sid=1")
+
+	rep, err := NewReplayer(path)
+	assert.NoError(t, err)
+	c = NewClient(&Config{Transport: rep, Stat: new(fakeStat)})
+	assert.NoError(t, c.PostJSONInto(context.Background(), srv.URL+"/orders", "a", &o))
+	assert.Equal(t, order{ID: 1, Name: "a"}, o)
+	assert.Len(t, rep.Unused(), 2)
+	assert.NoError(t, c.GetInto(context.Background(), srv.URL+"/orders", &o))
+	assert.Equal(t, 2, o.ID)
+	assert.NoError(t, c.GetInto(context.Background(), srv.URL+"/orders", &o))
+	assert.Equal(t, 3, o.ID, "repeated requests are replayed in order")
+	assert.Empty(t, rep.Unused())
+
+	err = c.GetInto(context.Background(), srv.URL+"/orders", &o)
+	assert.True(t, strings.Contains(err.Error(), "no recorded interaction"))
+	assert.Error(t, c.PostJSONInto(context.Background(), srv.URL+"/orders", "b", &o), "the body is matched")
+}
+
+func TestRecordError(t *testing.T) {
+	rec := &Recorder{Transport: RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})}
+	dir, err := ioutil.TempDir("", "cassette")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "error.json")
+
+	_, err = rec.RoundTrip(mustRequest("http://example.com/a"))
+	assert.Error(t, err)
+	assert.NoError(t, rec.Save(path))
+
+	rep, err := NewReplayer(path)
+	assert.NoError(t, err)
+	_, err = rep.RoundTrip(mustRequest("http://example.com/a"))
+	assert.EqualError(t, err, "connection refused")
+}
+
+func TestBodyBinary(t *testing.T) {
+	for _, b := range [][]byte{nil, []byte("text"), {0xff, 0x00, 0xfe}} {
+		assert.Equal(t, b, newBody(b).Bytes())
+	}
+	assert.Empty(t, newBody([]byte{0xff}).Text)
+}
@@ -5,9 +5,6 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
@@ -20,11 +17,7 @@ func Get(uri string) ([]byte, error) {
 		return nil, err
 	}
 
-	defer response.Body.Close()
-	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("http get error : uri=%v , statusCode=%v", uri, response.StatusCode)
-	}
-	return ioutil.ReadAll(response.Body)
+	return readBody(response)
 }
 
 //PostJSON post json 数据请求
@@ -43,12 +36,7 @@ func PostJSON(uri string, obj interface{}) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("http get error : uri=%v , statusCode=%v", uri, response.StatusCode)
-	}
-	return ioutil.ReadAll(response.Body)
+	return readBody(response)
 }
 
 //PostFile 上传文件
@@ -56,7 +44,7 @@ func PostFile(fieldName, fileName, uri string) ([]byte, error) {
 	fields := []MultipartFormField{
 		{
 			IsFile:    true,
-			Fieldname: fileName,
+			Fieldname: fieldName,
 			Filename:  fileName,
 		},
 	}
@@ -71,56 +59,31 @@ type MultipartFormField struct {
 	Filename  string
 }
 
-//PostMultipartForm 上传文件或其他多个字段
+//PostMultipartForm 上传文件或其他多个字段, 文件边读边传
 func PostMultipartForm(fields []MultipartFormField, uri string) (respBody []byte, err error) {
-	bodyBuf := &bytes.Buffer{}
-	bodyWriter := multipart.NewWriter(bodyBuf)
-
+	parts := make([]Part, 0, len(fields))
 	for _, field := range fields {
-		if field.IsFile {
-			fileWriter, e := bodyWriter.CreateFormFile(field.Fieldname, field.Filename)
-			if e != nil {
-				err = fmt.Errorf("error writing to buffer , err=%v", e)
-				return
-			}
-
-			fh, e := os.Open(field.Filename)
-			if e != nil {
-				err = fmt.Errorf("error opening file , err=%v", e)
-				return
-			}
-			defer fh.Close()
-
-			if _, err = io.Copy(fileWriter, fh); err != nil {
-				return
-			}
-		} else {
-			partWriter, e := bodyWriter.CreateFormField(field.Fieldname)
-			if e != nil {
-				err = e
-				return
-			}
-			valueReader := bytes.NewReader(field.Value)
-			if _, err = io.Copy(partWriter, valueReader); err != nil {
-				return
-			}
+		if !field.IsFile {
+			parts = append(parts, Part{FieldName: field.Fieldname, Reader: bytes.NewReader(field.Value), Size: int64(len(field.Value))})
+			continue
+		}
+		fh, e := os.Open(field.Filename)
+		if e != nil {
+			err = fmt.Errorf("error opening file , err=%v", e)
+			return
 		}
+		defer fh.Close()
+		parts = append(parts, Part{FieldName: field.Fieldname, FileName: field.Filename, Reader: fh, Size: -1})
 	}
 
-	contentType := bodyWriter.FormDataContentType()
-	bodyWriter.Close()
-
-	resp, e := http.Post(uri, contentType, bodyBuf)
+	body, contentType := multipartBody(parts, nil)
+	defer body.Close()
+	resp, e := http.Post(uri, contentType, body)
 	if e != nil {
 		err = e
 		return
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, err
-	}
-	respBody, err = ioutil.ReadAll(resp.Body)
-	return
+	return readBody(resp)
 }
 
 //PostXML perform a HTTP/POST request with XML body
@@ -135,12 +98,7 @@ func PostXML(uri string, obj interface{}) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("http code error : uri=%v , statusCode=%v", uri, response.StatusCode)
-	}
-	return ioutil.ReadAll(response.Body)
+	return readBody(response)
 }
 
 // PostFromURLEncoded .
@@ -149,10 +107,5 @@ func PostFromURLEncoded(uri string, params url.Values) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("http get error : uri=%v , statusCode=%v", uri, response.StatusCode)
-	}
-	return ioutil.ReadAll(response.Body)
+	return readBody(response)
 }
@@ -0,0 +1,200 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/any-lyu/go.library/errors"
+)
+
+// maxErrorBody is the max bytes of body kept by a StatusError.
+const maxErrorBody = 4 << 10
+
+// StatusError is the error of a response whose status is not 2xx.
+type StatusError struct {
+	Method string
+	URL    string
+	Status int
+	Header http.Header
+	// Body is the start of the body, at most 4KB.
+	Body []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http: %s %s status %d: %s", e.Method, e.URL, e.Status, e.Body)
+}
+
+// checkStatus returns a *StatusError for a non 2xx response, its body is
+// read and closed.
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	e := &StatusError{Status: resp.StatusCode, Header: resp.Header, Body: body}
+	if resp.Request != nil {
+		e.Method, e.URL = resp.Request.Method, resp.Request.URL.String()
+	}
+	return e
+}
+
+// readBody reads the body of a 2xx response, or returns a *StatusError.
+func readBody(resp *http.Response) ([]byte, error) {
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Codec encodes and decodes bodies of a media type.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type xmlCodec struct{}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error)      { return xml.Marshal(v) }
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error { return xml.Unmarshal(data, v) }
+
+type protobufCodec struct{}
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, errors.Errorf("http: protobuf codec of %T", v)
+	}
+	return proto.Marshal(msg)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return errors.Errorf("http: protobuf codec of %T", v)
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// Codecs.
+var (
+	JSONCodec     Codec = jsonCodec{}
+	XMLCodec      Codec = xmlCodec{}
+	ProtobufCodec Codec = protobufCodec{}
+)
+
+var codecs = struct {
+	sync.RWMutex
+	m map[string]Codec
+}{m: map[string]Codec{
+	"application/json":       JSONCodec,
+	"application/xml":        XMLCodec,
+	"text/xml":               XMLCodec,
+	"application/x-protobuf": ProtobufCodec,
+	"application/protobuf":   ProtobufCodec,
+}}
+
+// RegisterCodec registers the codec of a media type, such as
+// "application/msgpack". It is safe to call while decoding.
+func RegisterCodec(mediaType string, c Codec) {
+	codecs.Lock()
+	codecs.m[mediaType] = c
+	codecs.Unlock()
+}
+
+// CodecOf returns the codec of a Content-Type, types with a +json or +xml
+// suffix use the json or xml codec, a missing Content-Type json.
+func CodecOf(contentType string) (Codec, error) {
+	if contentType == "" {
+		return JSONCodec, nil
+	}
+	typ, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errors.Wrapf(err, "http: content type %q", contentType)
+	}
+	codecs.RLock()
+	c, ok := codecs.m[typ]
+	codecs.RUnlock()
+	switch {
+	case ok:
+		return c, nil
+	case strings.HasSuffix(typ, "+json"):
+		return JSONCodec, nil
+	case strings.HasSuffix(typ, "+xml"):
+		return XMLCodec, nil
+	}
+	return nil, errors.Errorf("http: no codec of content type %q", contentType)
+}
+
+// Decode decodes the body of resp into v by the codec of its Content-Type
+// and closes it. A non 2xx response is returned as a *StatusError, an empty
+// body leaves v as is, and a *[]byte v gets the body as is.
+func Decode(resp *http.Response, v interface{}) error {
+	body, err := readBody(resp)
+	if err != nil {
+		return err
+	}
+	if b, ok := v.(*[]byte); ok {
+		*b = body
+		return nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 || v == nil {
+		return nil
+	}
+	c, err := CodecOf(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(body, v)
+}
+
+// DoInto sends req by Do and decodes the response into v.
+func (c *Client) DoInto(ctx context.Context, req *http.Request, v interface{}) error {
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+	return Decode(resp, v)
+}
+
+// GetInto gets uri into v.
+func (c *Client) GetInto(ctx context.Context, uri string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	return c.DoInto(ctx, req, v)
+}
+
+// PostJSONInto posts in as json to uri and decodes the response into out.
+func (c *Client) PostJSONInto(ctx context.Context, uri string, in, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, uri, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json;charset=utf-8")
+	req.Header.Set("Accept", "application/json")
+	return c.DoInto(ctx, req, out)
+}
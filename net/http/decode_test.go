@@ -0,0 +1,94 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type order struct {
+	ID   int    `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
+}
+
+func respond(status int, contentType, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Header().Set("X-Request-Id", "r1")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestDecode(t *testing.T) {
+	c := newTestClient(new(fakeStat), 0)
+	for _, tt := range []struct {
+		status      int
+		contentType string
+		body        string
+	}{
+		{200, "application/json; charset=utf-8", `{"id":1,"name":"a"}`},
+		{201, "application/problem+json", `{"id":1,"name":"a"}`},
+		{202, "text/xml", `<order><id>1</id><name>a</name></order>`},
+	} {
+		srv := respond(tt.status, tt.contentType, tt.body)
+		var o order
+		assert.NoError(t, c.GetInto(context.Background(), srv.URL, &o), tt.contentType)
+		assert.Equal(t, order{ID: 1, Name: "a"}, o, tt.contentType)
+		srv.Close()
+	}
+
+	codec, err := CodecOf("")
+	assert.NoError(t, err)
+	assert.Equal(t, JSONCodec, codec, "json without Content-Type")
+
+	srv := respond(http.StatusNoContent, "", "")
+	defer srv.Close()
+	o := order{ID: 2}
+	assert.NoError(t, c.GetInto(context.Background(), srv.URL, &o))
+	assert.Equal(t, order{ID: 2}, o)
+
+	srv2 := respond(http.StatusOK, "text/csv", "a,b")
+	defer srv2.Close()
+	assert.Error(t, c.GetInto(context.Background(), srv2.URL, &o))
+	var raw []byte
+	assert.NoError(t, c.GetInto(context.Background(), srv2.URL, &raw))
+	assert.Equal(t, "a,b", string(raw))
+}
+
+func TestStatusError(t *testing.T) {
+	body := `{"code":-400,"msg":"bad"}` + strings.Repeat(" ", 2*maxErrorBody)
+	srv := respond(http.StatusBadRequest, "application/json", body)
+	defer srv.Close()
+
+	var o order
+	err := newTestClient(new(fakeStat), 0).GetInto(context.Background(), srv.URL, &o)
+	se, ok := err.(*StatusError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusBadRequest, se.Status)
+	assert.Equal(t, "r1", se.Header.Get("X-Request-Id"))
+	assert.Len(t, se.Body, maxErrorBody)
+	assert.True(t, strings.HasPrefix(string(se.Body), `{"code":-400,"msg":"bad"}`))
+	assert.Equal(t, http.MethodGet, se.Method)
+	assert.Equal(t, srv.URL, se.URL)
+
+	// the package funcs too
+	_, err = Get(srv.URL)
+	_, ok = err.(*StatusError)
+	assert.True(t, ok)
+	_, err = PostMultipartForm([]MultipartFormField{{Fieldname: "a", Value: []byte("1")}}, srv.URL)
+	_, ok = err.(*StatusError)
+	assert.True(t, ok, "PostMultipartForm fails on non 2xx")
+
+	srv2 := respond(http.StatusCreated, "", "created")
+	defer srv2.Close()
+	b, err := PostJSON(srv2.URL, nil)
+	assert.NoError(t, err, "any 2xx succeeds")
+	assert.Equal(t, "created", string(b))
+}
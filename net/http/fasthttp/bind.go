@@ -0,0 +1,339 @@
+package fasthttp
+
+import (
+	"bytes"
+	"encoding"
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/errors"
+	"github.com/any-lyu/go.library/json"
+)
+
+// Bind fills the struct pointed by v from the request then validates it.
+//
+// Fields are filled from the body, then path params (tag path, the string
+// user values set by the router) and the query (tag query), which take
+// precedence over the body. The body is decoded by its Content-Type:
+// application/json is unmarshaled into v, form and multipart values fill
+// fields tagged form, multipart files fill *multipart.FileHeader and
+// []*multipart.FileHeader fields. The validate tag is checked as Validate.
+//
+//	type createOrder struct {
+//		Shop  int64    `path:"shop"`
+//		Debug bool     `query:"debug"`
+//		SKU   string   `json:"sku" validate:"required,regex=^[A-Z]+$"`
+//		Qty   int      `json:"qty" validate:"min=1,max=99"`
+//	}
+//
+// Bad values are returned as FieldErrors, which HandlerFuncWrapper renders
+// as the data of a errors.ErrParams response.
+func Bind(ctx *fasthttp.RequestCtx, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("bind: %T is not a pointer to struct", v)
+	}
+	info, err := structInfoOf(rv.Elem().Type())
+	if err != nil {
+		return err
+	}
+	var errs FieldErrors
+	ct := ctx.Request.Header.ContentType()
+	if i := bytes.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	switch string(bytes.TrimSpace(ct)) {
+	case "application/json":
+		if body := ctx.PostBody(); len(body) > 0 {
+			if err := json.Unmarshal(body, v); err != nil {
+				errs = append(errs, &FieldError{Rule: "json", Message: err.Error()})
+			}
+		}
+	case "application/x-www-form-urlencoded":
+		errs = info.fill(rv.Elem(), "form", argsValues(ctx.PostArgs()), nil, errs)
+	case "multipart/form-data":
+		form, err := ctx.MultipartForm()
+		if err != nil {
+			errs = append(errs, &FieldError{Rule: "multipart", Message: err.Error()})
+			break
+		}
+		errs = info.fill(rv.Elem(), "form", func(name string) []string {
+			return form.Value[name]
+		}, form.File, errs)
+	case "":
+		if len(ctx.PostBody()) > 0 {
+			errs = append(errs, &FieldError{Rule: "content-type", Message: "missing Content-Type"})
+		}
+	default:
+		errs = append(errs, &FieldError{Rule: "content-type", Message: "unsupported Content-Type " + string(ct)})
+	}
+	// NOTE path and query are filled after the body, so the body can not
+	// override them.
+	errs = info.fill(rv.Elem(), "path", func(name string) []string {
+		if s, ok := ctx.UserValue(name).(string); ok {
+			return []string{s}
+		}
+		return nil
+	}, nil, errs)
+	errs = info.fill(rv.Elem(), "query", argsValues(ctx.QueryArgs()), nil, errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return Validate(v)
+}
+
+func argsValues(args *fasthttp.Args) func(name string) []string {
+	return func(name string) []string {
+		multi := args.PeekMulti(name)
+		if len(multi) == 0 {
+			return nil
+		}
+		vs := make([]string, len(multi))
+		for i, b := range multi {
+			vs[i] = string(b)
+		}
+		return vs
+	}
+}
+
+// FieldError a request field failing binding or validation.
+type FieldError struct {
+	Field   string `json:"field"`   // field name as in the request, "" for the whole body
+	Rule    string `json:"rule"`    // the failed rule, e.g. required, min, type, json
+	Message string `json:"message"` // human readable reason
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// FieldErrors the field errors of a request, its errors.Cause is
+// errors.ErrParams.
+type FieldErrors []*FieldError
+
+func (e FieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return errors.ErrParams.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+// Cause returns errors.ErrParams.
+func (e FieldErrors) Cause() error {
+	return errors.ErrParams
+}
+
+// fieldErrors finds FieldErrors in the cause chain of err.
+func fieldErrors(err error) (FieldErrors, bool) {
+	for err != nil {
+		if fe, ok := err.(FieldErrors); ok {
+			return fe, true
+		}
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			return nil, false
+		}
+		err = c.Cause()
+	}
+	return nil, false
+}
+
+type fieldInfo struct {
+	index []int
+	name  string            // name in errors: json name or Go name
+	tags  map[string]string // source to name: path, query, form
+	rules []*rule
+	sub   *structInfo // struct, *struct or []struct field validated recursively
+}
+
+type structInfo struct {
+	fields []*fieldInfo
+}
+
+var structInfos sync.Map // reflect.Type to *structInfo
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func structInfoOf(t reflect.Type) (*structInfo, error) {
+	if v, ok := structInfos.Load(t); ok {
+		return v.(*structInfo), nil
+	}
+	info, err := newStructInfo(t, map[reflect.Type]*structInfo{})
+	if err != nil {
+		return nil, err
+	}
+	structInfos.Store(t, info)
+	return info, nil
+}
+
+func newStructInfo(t reflect.Type, seen map[reflect.Type]*structInfo) (*structInfo, error) {
+	if info, ok := seen[t]; ok {
+		return info, nil
+	}
+	info := new(structInfo)
+	seen[t] = info
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			embedded, err := newStructInfo(sf.Type, seen)
+			if err != nil {
+				return nil, err
+			}
+			for _, ef := range embedded.fields {
+				f := *ef
+				f.index = append(append([]int(nil), sf.Index...), ef.index...)
+				info.fields = append(info.fields, &f)
+			}
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		f := &fieldInfo{index: sf.Index, name: sf.Name, tags: map[string]string{}}
+		if name := strings.Split(sf.Tag.Get("json"), ",")[0]; name == "-" {
+			continue
+		} else if name != "" {
+			f.name = name
+		}
+		for _, src := range []string{"path", "query", "form"} {
+			if name := sf.Tag.Get(src); name != "" {
+				f.tags[src] = name
+				if sf.Tag.Get("json") == "" {
+					f.name = name
+				}
+			}
+		}
+		rules, err := parseRules(sf.Tag.Get("validate"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "bind: %s.%s", t, sf.Name)
+		}
+		f.rules = rules
+		if et := structElem(sf.Type); et != nil {
+			if f.sub, err = newStructInfo(et, seen); err != nil {
+				return nil, err
+			}
+		}
+		if len(f.tags) > 0 || len(f.rules) > 0 || f.sub != nil {
+			info.fields = append(info.fields, f)
+		}
+	}
+	return info, nil
+}
+
+// structElem returns the struct type of struct, *struct and []struct, nil
+// for others and for structs decoding themselves such as time.Time.
+func structElem(t reflect.Type) reflect.Type {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		t = t.Elem()
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+	if t.Kind() != reflect.Struct || reflect.PtrTo(t).Implements(textUnmarshaler) || t.NumField() == 0 {
+		return nil
+	}
+	return t
+}
+
+func (info *structInfo) fill(v reflect.Value, src string, values func(string) []string,
+	files map[string][]*multipart.FileHeader, errs FieldErrors) FieldErrors {
+	for _, f := range info.fields {
+		name, ok := f.tags[src]
+		if !ok {
+			continue
+		}
+		fv := v.FieldByIndex(f.index)
+		if files != nil {
+			if fhs := files[name]; len(fhs) > 0 {
+				switch {
+				case fv.Type() == fileHeaderType:
+					fv.Set(reflect.ValueOf(fhs[0]))
+					continue
+				case fv.Type() == reflect.SliceOf(fileHeaderType):
+					fv.Set(reflect.ValueOf(fhs))
+					continue
+				}
+			}
+		}
+		vs := values(name)
+		if len(vs) == 0 {
+			continue
+		}
+		if err := setValue(fv, vs); err != nil {
+			errs = append(errs, &FieldError{Field: name, Rule: "type", Message: err.Error()})
+		}
+	}
+	return errs
+}
+
+func setValue(v reflect.Value, vs []string) error {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		s := reflect.MakeSlice(v.Type(), len(vs), len(vs))
+		for i, str := range vs {
+			if err := setString(s.Index(i), str); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return setString(v, vs[0])
+}
+
+func setString(v reflect.Value, s string) error {
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshaler) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := setString(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.Errorf("%q is not a bool", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.Errorf("%q is not a %s", s, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.Errorf("%q is not a %s", s, v.Type())
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return errors.Errorf("%q is not a number", s)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		v.SetBytes([]byte(s))
+	default:
+		return errors.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package fasthttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/errors"
+)
+
+type item struct {
+	SKU string `json:"sku" validate:"required,regex=^[A-Z]{3},[0-9]+$"`
+	Qty int    `json:"qty" validate:"min=1,max=99"`
+}
+
+type paging struct {
+	Page int `query:"page" validate:"min=1"`
+}
+
+type createOrder struct {
+	Shop   int64    `path:"shop" validate:"required"`
+	Debug  bool     `query:"debug"`
+	Tags   []string `query:"tag" validate:"max=2"`
+	Status string   `json:"status" validate:"enum=new|paid"`
+	Items  []*item  `json:"items" validate:"required"`
+	paging
+}
+
+func request(contentType string, body []byte) *fasthttp.RequestCtx {
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetRequestURI("/shops/7/orders?debug=true&tag=a&tag=b&page=2")
+	ctx.Request.Header.SetContentType(contentType)
+	ctx.Request.SetBody(body)
+	ctx.SetUserValue("shop", "7")
+	return ctx
+}
+
+func TestBindJSON(t *testing.T) {
+	var req createOrder
+	ctx := request("application/json; charset=utf-8", []byte(`{"status":"new","items":[{"sku":"ABC,1","qty":2}]}`))
+	assert.NoError(t, Bind(ctx, &req))
+	assert.Equal(t, int64(7), req.Shop)
+	assert.True(t, req.Debug)
+	assert.Equal(t, []string{"a", "b"}, req.Tags)
+	assert.Equal(t, "new", req.Status)
+	assert.Equal(t, 2, req.Page)
+	assert.Equal(t, &item{SKU: "ABC,1", Qty: 2}, req.Items[0])
+
+	req = createOrder{}
+	ctx = request("application/json", []byte(`{"status":"gone","items":[{"sku":"abc","qty":100},{"qty":0}]}`))
+	ctx.Request.SetRequestURI("/?tag=a&tag=b&tag=c&page=x")
+	err := Bind(ctx, &req)
+	assert.Equal(t, errors.ErrParams, errors.Cause(err))
+	assert.Equal(t, FieldErrors{{Field: "page", Rule: "type", Message: `"x" is not a int`}}, err)
+
+	ctx.Request.SetRequestURI("/?tag=a&tag=b&tag=c")
+	err = Bind(ctx, &req)
+	var fields []string
+	for _, fe := range err.(FieldErrors) {
+		fields = append(fields, fe.Field+" "+fe.Rule)
+	}
+	assert.Equal(t, []string{
+		"tag max", "status enum", "items[0].sku regex", "items[0].qty max", "items[1].sku required",
+		"items[1].qty min", "page min",
+	}, fields)
+
+	err = Bind(request("application/json", []byte(`{"items":`)), &req)
+	assert.Equal(t, "json", err.(FieldErrors)[0].Rule)
+	err = Bind(request("text/plain", []byte(`x`)), &req)
+	assert.Equal(t, "content-type", err.(FieldErrors)[0].Rule)
+}
+
+type upload struct {
+	Name  string                `form:"name" validate:"required,max=8"`
+	Sizes []int                 `form:"size"`
+	File  *multipart.FileHeader `form:"file" validate:"required"`
+}
+
+func TestBindPrecedence(t *testing.T) {
+	var req struct {
+		Shop  int64 `json:"shop" path:"shop"`
+		Page  int   `json:"page" query:"page"`
+		Limit *int  `json:"limit" validate:"min=1"`
+	}
+	ctx := request("application/json", []byte(`{"shop":999,"page":5}`))
+	assert.NoError(t, Bind(ctx, &req))
+	assert.Equal(t, int64(7), req.Shop)
+	assert.Equal(t, 2, req.Page)
+	assert.Nil(t, req.Limit)
+
+	ctx = request("application/json", []byte(`{"limit":0}`))
+	assert.Equal(t, FieldErrors{{Field: "limit", Rule: "min", Message: "must be at least 1"}}, Bind(ctx, &req))
+}
+
+func TestBindForm(t *testing.T) {
+	var req upload
+	ctx := request("application/x-www-form-urlencoded", []byte("name=report&size=1&size=2"))
+	err := Bind(ctx, &req)
+	assert.Equal(t, FieldErrors{{Field: "file", Rule: "required", Message: "is required"}}, err)
+	assert.Equal(t, "report", req.Name)
+	assert.Equal(t, []int{1, 2}, req.Sizes)
+
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	w.WriteField("name", "report")
+	fw, _ := w.CreateFormFile("file", "a.csv")
+	fw.Write([]byte("a,b"))
+	w.Close()
+	req = upload{}
+	assert.NoError(t, Bind(request(w.FormDataContentType(), body.Bytes()), &req))
+	assert.Equal(t, "report", req.Name)
+	assert.Equal(t, "a.csv", req.File.Filename)
+}
+
+func TestHandlerFuncWrapperFieldErrors(t *testing.T) {
+	h := HandlerFuncWrapper(func(ctx *fasthttp.RequestCtx) (interface{}, error) {
+		var req upload
+		if err := Bind(ctx, &req); err != nil {
+			return nil, errors.Wrap(err, "bind upload")
+		}
+		return req.Name, nil
+	})
+	ctx := request("application/x-www-form-urlencoded", []byte("name=far+too+long"))
+	h(ctx)
+	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+	var resp struct {
+		Code int           `json:"code"`
+		Data []*FieldError `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(ctx.Response.Body(), &resp))
+	assert.Equal(t, errors.ErrCode(errors.ErrParams), resp.Code)
+	assert.Equal(t, []*FieldError{
+		{Field: "name", Rule: "max", Message: "must be at most 8 characters"},
+		{Field: "file", Rule: "required", Message: "is required"},
+	}, resp.Data)
+}
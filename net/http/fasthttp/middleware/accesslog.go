@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/logs"
+	xfasthttp "github.com/any-lyu/go.library/net/http/fasthttp"
+	"github.com/any-lyu/go.library/stat"
+)
+
+// AccessLogEntry the fields of an access log line.
+type AccessLogEntry struct {
+	Time     time.Time `json:"time"`
+	Method   string    `json:"method"`
+	Route    string    `json:"route,omitempty"` // route template, empty if no route matched
+	Path     string    `json:"path"`
+	Query    string    `json:"query,omitempty"`
+	Status   int       `json:"status"`
+	Latency  float64   `json:"latency_ms"`
+	BytesIn  int       `json:"bytes_in"`
+	BytesOut int       `json:"bytes_out"` // Content-Length of streamed bodies, -1 if unknown
+	ClientIP string    `json:"client_ip"`
+	User     string    `json:"user,omitempty"`
+	TraceID  string    `json:"trace_id,omitempty"`
+	Body     string    `json:"body,omitempty"`
+}
+
+// AccessLogConfig access log config.
+type AccessLogConfig struct {
+	// BodyLimit is the max bytes of request body logged, 0 logs no body.
+	// JSON and form bodies are logged redacted, others by their size.
+	BodyLimit int
+	// Redact are the json keys, form and query keys whose values are
+	// replaced by ***, case insensitive, default DefaultRedact.
+	Redact []string
+	// TrustedProxies whose X-Forwarded-For is believed for the client ip.
+	TrustedProxies []*net.IPNet
+	// Skip skips logging requests, e.g. health checks, they are still
+	// counted in Stat.
+	Skip func(ctx *fasthttp.RequestCtx) bool
+	// TraceID returns the trace id of a request, default the trace id of
+	// the NewTracing span, or the X-Request-Id header.
+	TraceID func(ctx *fasthttp.RequestCtx) string
+	// Output writes entries, default one JSON line by logs.Info.
+	Output func(e *AccessLogEntry)
+	// Stat receives latency and code of requests by route, default
+	// stat.HTTPServer.
+	Stat stat.Stat
+}
+
+// DefaultRedact the default redacted keys.
+var DefaultRedact = []string{"password", "passwd", "secret", "token", "access_token", "refresh_token", "authorization"}
+
+const (
+	redacted     = "***"
+	maxParseBody = 1 << 20
+)
+
+// NewAccessLog new a structured access log middleware.
+func NewAccessLog(c *AccessLogConfig) Middleware {
+	conf := *c
+	if conf.Redact == nil {
+		conf.Redact = DefaultRedact
+	}
+	redact := make(map[string]bool, len(conf.Redact))
+	for _, k := range conf.Redact {
+		redact[strings.ToLower(k)] = true
+	}
+	if conf.TraceID == nil {
+		conf.TraceID = func(ctx *fasthttp.RequestCtx) string {
+			if id := TraceID(ctx); id != "" {
+				return id
+			}
+			return string(ctx.Request.Header.Peek("X-Request-Id"))
+		}
+	}
+	if conf.Output == nil {
+		conf.Output = func(e *AccessLogEntry) {
+			b, _ := json.Marshal(e)
+			logs.Info("%s", b)
+		}
+	}
+	if conf.Stat == nil {
+		conf.Stat = stat.HTTPServer
+	}
+	return func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			start := time.Now()
+			h(ctx)
+			latency := time.Since(start)
+
+			route := xfasthttp.RouteTemplate(ctx)
+			label := route
+			if label == "" {
+				label = "unmatched"
+			}
+			label = string(ctx.Method()) + " " + label
+			// the user label is kept constant, a series per user is unbounded
+			conf.Stat.Timing("no_user", int64(latency/time.Millisecond), label)
+			conf.Stat.Incr("no_user", label, strconv.Itoa(ctx.Response.StatusCode()))
+
+			if conf.Skip != nil && conf.Skip(ctx) {
+				return
+			}
+			e := &AccessLogEntry{
+				Time:     start,
+				Method:   string(ctx.Method()),
+				Route:    route,
+				Path:     string(ctx.Path()),
+				Query:    redactArgs(ctx.QueryArgs(), redact),
+				Status:   ctx.Response.StatusCode(),
+				Latency:  float64(latency) / float64(time.Millisecond),
+				BytesIn:  len(ctx.Request.Body()),
+				BytesOut: bytesOut(&ctx.Response),
+				ClientIP: clientIP(ctx, conf.TrustedProxies),
+				TraceID:  conf.TraceID(ctx),
+			}
+			if p, ok := PrincipalFrom(ctx); ok {
+				e.User = p.ID
+			}
+			if conf.BodyLimit > 0 && len(ctx.Request.Body()) > 0 {
+				e.Body = redactBody(ctx, redact, conf.BodyLimit)
+			}
+			conf.Output(e)
+		}
+	}
+}
+
+// bytesOut is the size of the response body. A streamed body is not read,
+// which would buffer it, but sized by its Content-Length.
+func bytesOut(resp *fasthttp.Response) int {
+	if resp.IsBodyStream() {
+		return resp.Header.ContentLength()
+	}
+	return len(resp.Body())
+}
+
+func redactArgs(args *fasthttp.Args, redact map[string]bool) string {
+	if args.Len() == 0 {
+		return ""
+	}
+	vs := url.Values{}
+	args.VisitAll(func(k, v []byte) {
+		key := string(k)
+		if redact[strings.ToLower(key)] {
+			vs.Add(key, redacted)
+			return
+		}
+		vs.Add(key, string(v))
+	})
+	return vs.Encode()
+}
+
+func redactBody(ctx *fasthttp.RequestCtx, redact map[string]bool, limit int) string {
+	body := ctx.Request.Body()
+	ct := string(ctx.Request.Header.ContentType())
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	size := "<" + strconv.Itoa(len(body)) + " bytes>"
+	if len(body) > maxParseBody {
+		return size
+	}
+	var s string
+	switch strings.TrimSpace(ct) {
+	case "application/json":
+		var v interface{}
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+		if err := d.Decode(&v); err != nil {
+			return size
+		}
+		b, _ := json.Marshal(redactJSON(v, redact))
+		s = string(b)
+	case "application/x-www-form-urlencoded":
+		s = redactArgs(ctx.PostArgs(), redact)
+	default:
+		return size
+	}
+	if len(s) > limit {
+		s = s[:limit] + "...(truncated)"
+	}
+	return s
+}
+
+func redactJSON(v interface{}, redact map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if redact[strings.ToLower(k)] {
+				v[k] = redacted
+			} else {
+				v[k] = redactJSON(e, redact)
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = redactJSON(e, redact)
+		}
+	}
+	return v
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	xfasthttp "github.com/any-lyu/go.library/net/http/fasthttp"
+)
+
+type fakeStat struct {
+	timings []string
+	codes   []string
+}
+
+func (s *fakeStat) Timing(name string, time int64, extra ...string) {
+	s.timings = append(s.timings, name+" "+strings.Join(extra, " "))
+}
+
+func (s *fakeStat) Incr(name string, extra ...string) {
+	s.codes = append(s.codes, name+" "+strings.Join(extra, " "))
+}
+
+func (s *fakeStat) State(name string, val int64, extra ...string) {}
+
+func TestAccessLog(t *testing.T) {
+	var entries []*AccessLogEntry
+	st := new(fakeStat)
+	m := NewAccessLog(&AccessLogConfig{
+		BodyLimit: 128,
+		Output:    func(e *AccessLogEntry) { entries = append(entries, e) },
+		Stat:      st,
+		Skip:      func(ctx *fasthttp.RequestCtx) bool { return string(ctx.Path()) == "/healthz" },
+	})
+	r := xfasthttp.NewRouter()
+	r.POST("/users/:id", func(ctx *fasthttp.RequestCtx) {
+		ctx.SetUserValue(principalKey, &Principal{ID: "42"})
+		ctx.SetStatusCode(fasthttp.StatusCreated)
+		ctx.WriteString("created")
+	})
+	r.GET("/healthz", func(ctx *fasthttp.RequestCtx) {})
+	h := m(r.Handler)
+	request := func(uri, contentType, body string) {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Init(&fasthttp.Request{}, &net.TCPAddr{IP: net.ParseIP("1.2.3.4")}, nil)
+		ctx.Request.Header.SetMethod("POST")
+		ctx.Request.SetRequestURI(uri)
+		ctx.Request.Header.Set("X-Request-Id", "req-1")
+		ctx.Request.Header.SetContentType(contentType)
+		ctx.Request.SetBodyString(body)
+		h(ctx)
+	}
+
+	request("/users/7?token=abc&page=1", "application/json",
+		`{"name":"tom","Password":"p@ss","devices":[{"token":"t1","os":"ios"}]}`)
+	assert.Len(t, entries, 1)
+	e := entries[0]
+	assert.Equal(t, "POST", e.Method)
+	assert.Equal(t, "/users/:id", e.Route)
+	assert.Equal(t, "/users/7", e.Path)
+	assert.Equal(t, "page=1&token=%2A%2A%2A", e.Query)
+	assert.Equal(t, fasthttp.StatusCreated, e.Status)
+	assert.Equal(t, 7, e.BytesOut)
+	assert.Equal(t, "1.2.3.4", e.ClientIP)
+	assert.Equal(t, "42", e.User)
+	assert.Equal(t, "req-1", e.TraceID)
+	assert.Equal(t, `{"Password":"***","devices":[{"os":"ios","token":"***"}],"name":"tom"}`, e.Body)
+	assert.NotContains(t, mustJSON(e), "p@ss")
+
+	request("/users/8", "application/x-www-form-urlencoded", "name=tom&password=secret")
+	assert.Equal(t, "name=tom&password=%2A%2A%2A", entries[1].Body)
+	request("/users/9", "application/json", `{"note":"`+strings.Repeat("x", 200)+`"}`)
+	assert.Equal(t, 128+len("...(truncated)"), len(entries[2].Body))
+	request("/users/9", "application/octet-stream", "password")
+	assert.Equal(t, "<8 bytes>", entries[3].Body)
+	request("/users/9", "application/json", `{"password":`)
+	assert.Equal(t, "<12 bytes>", entries[4].Body)
+
+	request("/healthz", "", "")
+	request("/missing", "", "")
+	assert.Len(t, entries, 6)
+	assert.Equal(t, []string{
+		"no_user POST /users/:id 201", "no_user POST /users/:id 201", "no_user POST /users/:id 201",
+		"no_user POST /users/:id 201", "no_user POST /users/:id 201", "no_user POST unmatched 405",
+		"no_user POST unmatched 404",
+	}, st.codes)
+}
+
+func TestAccessLogStream(t *testing.T) {
+	var entries []*AccessLogEntry
+	m := NewAccessLog(&AccessLogConfig{Output: func(e *AccessLogEntry) { entries = append(entries, e) }, Stat: new(fakeStat)})
+	for _, size := range []int{5, -1} {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.SetRequestURI("/download")
+		m(func(ctx *fasthttp.RequestCtx) {
+			ctx.SetBodyStream(strings.NewReader("hello"), size)
+		})(ctx)
+		assert.True(t, ctx.Response.IsBodyStream(), "the stream is not read")
+		assert.Equal(t, size, entries[len(entries)-1].BytesOut)
+		assert.Equal(t, "hello", string(ctx.Response.Body()))
+	}
+}
+
+func mustJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -1,24 +1,176 @@
 package middleware
 
 import (
+	"strings"
+
 	"github.com/valyala/fasthttp"
 
+	"github.com/any-lyu/go.library/errors"
 	"github.com/any-lyu/go.library/jwt"
 )
 
-// BasicAuth is the basic auth handler
-func BasicAuth(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+const principalKey = "principal"
+
+// ErrNoCredentials is returned by an Authenticator when the request carries
+// no credentials of its scheme, the next authenticator is then tried.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	ID     string
+	Scheme string // the authenticator scheme, e.g. Bearer, ApiKey, Basic, HMAC
+	Roles  []string
+	Scopes []string
+	// Claims the verified token claims for Bearer, nil for other schemes.
+	Claims jwt.Claims
+}
+
+// HasRole reports whether the principal has role.
+func (p *Principal) HasRole(role string) bool {
+	return contains(p.Roles, role)
+}
+
+// HasScope reports whether the principal has scope.
+func (p *Principal) HasScope(scope string) bool {
+	return contains(p.Scopes, scope)
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// PrincipalFrom returns the principal stored by Auth.
+func PrincipalFrom(ctx *fasthttp.RequestCtx) (*Principal, bool) {
+	p, ok := ctx.UserValue(principalKey).(*Principal)
+	return p, ok
+}
+
+// Authenticator authenticates requests of one scheme.
+type Authenticator interface {
+	// Authenticate returns the principal of the request, or ErrNoCredentials
+	// when the request has no credentials of the scheme.
+	Authenticate(ctx *fasthttp.RequestCtx) (*Principal, error)
+	// Challenge returns the WWW-Authenticate value of the scheme, empty if
+	// the scheme has none.
+	Challenge() string
+}
+
+// Auth authenticates requests with the first authenticator finding
+// credentials, storing the principal for PrincipalFrom and its ID as the
+// "uid" user value. Requests without valid credentials get 401.
+func Auth(h fasthttp.RequestHandler, auths ...Authenticator) fasthttp.RequestHandler {
+	return auth(h, false, auths)
+}
+
+// AuthOptional is like Auth but passes requests without credentials on
+// anonymously, requests with invalid credentials still get 401. Use
+// RequireScopes on the routes needing a principal.
+func AuthOptional(h fasthttp.RequestHandler, auths ...Authenticator) fasthttp.RequestHandler {
+	return auth(h, true, auths)
+}
+
+func auth(h fasthttp.RequestHandler, optional bool, auths []Authenticator) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		for _, a := range auths {
+			p, err := a.Authenticate(ctx)
+			if err == ErrNoCredentials {
+				continue
+			}
+			if err != nil || p == nil {
+				unauthorized(ctx, auths)
+				return
+			}
+			ctx.SetUserValue(principalKey, p)
+			ctx.SetUserValue("uid", p.ID)
+			h(ctx)
+			return
+		}
+		if optional {
+			h(ctx)
+			return
+		}
+		unauthorized(ctx, auths)
+	}
+}
+
+func unauthorized(ctx *fasthttp.RequestCtx, auths []Authenticator) {
+	ctx.Error(fasthttp.StatusMessage(fasthttp.StatusUnauthorized), fasthttp.StatusUnauthorized)
+	for _, a := range auths {
+		if c := a.Challenge(); c != "" {
+			ctx.Response.Header.Add("WWW-Authenticate", c)
+		}
+	}
+}
+
+// RequireScopes lets requests through only when the principal has all the
+// scopes, 401 without a principal and 403 with missing scopes.
+func RequireScopes(h fasthttp.RequestHandler, scopes ...string) fasthttp.RequestHandler {
+	return require(h, func(p *Principal) bool {
+		for _, s := range scopes {
+			if !p.HasScope(s) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// RequireRoles lets requests through only when the principal has one of the
+// roles, 401 without a principal and 403 otherwise.
+func RequireRoles(h fasthttp.RequestHandler, roles ...string) fasthttp.RequestHandler {
+	return require(h, func(p *Principal) bool {
+		for _, r := range roles {
+			if p.HasRole(r) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func require(h fasthttp.RequestHandler, allow func(*Principal) bool) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
-		// Get the Basic Authentication credentials
-		tokenBytes := ctx.Request.Header.Peek("Authorization")
-		token, err := jwt.TokenParse(string(tokenBytes))
-		if err != nil {
-			// Request Basic Authentication otherwise
+		p, ok := PrincipalFrom(ctx)
+		if !ok {
 			ctx.Error(fasthttp.StatusMessage(fasthttp.StatusUnauthorized), fasthttp.StatusUnauthorized)
-			ctx.Response.Header.Set("WWW-Authenticate", "Basic realm=Restricted")
 			return
 		}
-		ctx.SetUserValue("uid", token.UID)
+		if !allow(p) {
+			ctx.Error(fasthttp.StatusMessage(fasthttp.StatusForbidden), fasthttp.StatusForbidden)
+			return
+		}
 		h(ctx)
 	}
 }
+
+// BasicAuth is the basic auth handler
+//
+// Deprecated: it parses a Bearer token by jwt.TokenParse, use Auth with
+// NewBearerJWT.
+func BasicAuth(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return Auth(h, tokenParseAuthenticator{})
+}
+
+// tokenParseAuthenticator authenticates by jwt.TokenParse.
+type tokenParseAuthenticator struct{}
+
+func (tokenParseAuthenticator) Authenticate(ctx *fasthttp.RequestCtx) (*Principal, error) {
+	header := string(ctx.Request.Header.Peek("Authorization"))
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, ErrNoCredentials
+	}
+	token, err := jwt.TokenParse(header)
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{ID: token.UID, Scheme: "Bearer", Claims: token}, nil
+}
+
+func (tokenParseAuthenticator) Challenge() string {
+	return `Bearer realm="Restricted"`
+}
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/jwt"
+	"github.com/any-lyu/go.library/rbac"
+	xtime "github.com/any-lyu/go.library/time"
+)
+
+func serve(h fasthttp.RequestHandler, auth string) *fasthttp.RequestCtx {
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.SetRequestURI("/orders?id=1")
+	if auth != "" {
+		ctx.Request.Header.Set("Authorization", auth)
+	}
+	h(ctx)
+	return ctx
+}
+
+func ok(ctx *fasthttp.RequestCtx) {
+	p, _ := PrincipalFrom(ctx)
+	ctx.SetBodyString(p.Scheme + ":" + p.ID)
+}
+
+func TestAuthBearer(t *testing.T) {
+	signer, err := jwt.NewSigner(&jwt.Config{
+		Expire: xtime.Duration(time.Hour),
+		Keys:   []*jwt.KeyConfig{{ID: "k1", Algorithm: "HS256", Secret: "secret"}},
+	})
+	assert.NoError(t, err)
+	token, err := signer.Sign(&AuthClaims{UID: "42", Roles: []string{"admin"}, Scope: "order:read order:write"})
+	assert.NoError(t, err)
+
+	bearer := NewBearerJWT(signer.Verifier())
+	h := Auth(RequireScopes(ok, "order:read"), bearer)
+
+	ctx := serve(h, "Bearer "+token)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, "Bearer:42", string(ctx.Response.Body()))
+	assert.Equal(t, "42", ctx.UserValue("uid"))
+
+	ctx = serve(h, "Bearer bad")
+	assert.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+	assert.Equal(t, `Bearer realm="Restricted"`, string(ctx.Response.Header.Peek("WWW-Authenticate")))
+
+	ctx = serve(Auth(RequireScopes(ok, "order:delete"), bearer), "Bearer "+token)
+	assert.Equal(t, fasthttp.StatusForbidden, ctx.Response.StatusCode())
+
+	ctx = serve(Auth(RequireRoles(ok, "ops", "admin"), bearer), "Bearer "+token)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+}
+
+func TestAuthChain(t *testing.T) {
+	basic := &Basic{Store: StaticCredentials{"tom": "pass"}, Realm: "ops"}
+	svc := &Principal{ID: "svc", Scopes: []string{"order:read"}}
+	key := &APIKey{Lookup: func(key string) (*Principal, error) {
+		if key == "k-1" {
+			return svc, nil
+		}
+		return nil, nil
+	}}
+	h := Auth(ok, key, basic)
+
+	ctx := serve(h, "Basic "+base64.StdEncoding.EncodeToString([]byte("tom:pass")))
+	assert.Equal(t, "Basic:tom", string(ctx.Response.Body()))
+
+	ctx = serve(h, "Basic "+base64.StdEncoding.EncodeToString([]byte("tom:nope")))
+	assert.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+
+	ctx = new(fasthttp.RequestCtx)
+	ctx.Request.Header.Set("X-API-Key", "k-1")
+	h(ctx)
+	assert.Equal(t, "ApiKey:svc", string(ctx.Response.Body()))
+	assert.Empty(t, svc.Scheme, "the shared principal is not changed")
+
+	ctx = serve(h, "")
+	assert.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+	assert.Equal(t, `Basic realm="ops", charset="UTF-8"`, string(ctx.Response.Header.Peek("WWW-Authenticate")))
+
+	ctx = serve(AuthOptional(func(ctx *fasthttp.RequestCtx) {
+		_, ok := PrincipalFrom(ctx)
+		assert.False(t, ok)
+	}, key, basic), "")
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	ctx = serve(AuthOptional(RequireScopes(ok), key, basic), "")
+	assert.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+}
+
+func TestAuthHMAC(t *testing.T) {
+	now := time.Now()
+	a := &HMAC{
+		Secret: func(key string) ([]byte, *Principal, error) {
+			if key == "svc" {
+				return []byte("secret"), &Principal{ID: "svc"}, nil
+			}
+			return nil, nil, nil
+		},
+		now: func() time.Time { return now },
+	}
+	h := Auth(ok, a)
+	request := func(key string, signed time.Time, body string) *fasthttp.RequestCtx {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.SetMethod("POST")
+		ctx.Request.SetRequestURI("/orders")
+		ctx.Request.SetBodyString(`{"id":1}`)
+		SignRequest(&ctx.Request, key, []byte("secret"), signed)
+		ctx.Request.SetBodyString(body)
+		h(ctx)
+		return ctx
+	}
+
+	ctx := request("svc", now, `{"id":1}`)
+	assert.Equal(t, "HMAC:svc", string(ctx.Response.Body()))
+
+	ctx = request("svc", now, `{"id":2}`)
+	assert.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+
+	ctx = request("svc", now.Add(-10*time.Minute), `{"id":1}`)
+	assert.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+
+	ctx = request("other", now, `{"id":1}`)
+	assert.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+}
+
+func TestAuthorize(t *testing.T) {
+	e, err := rbac.New(&rbac.Config{Roles: map[string]*rbac.RoleConfig{
+		"owner": {Rules: []*rbac.Rule{{
+			Actions:   []string{"update"},
+			Resources: []string{"orders/{owner}/*"},
+			When:      map[string]string{"resource.owner": "${subject.id}"},
+		}}},
+	}})
+	assert.NoError(t, err)
+	key := &APIKey{Lookup: func(key string) (*Principal, error) {
+		return &Principal{ID: key, Roles: []string{"owner"}}, nil
+	}}
+	h := Auth(Authorize(ok, e, "update", "orders/{owner}/{id}"), key)
+	request := func(key, owner string) int {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.Set("X-API-Key", key)
+		ctx.SetUserValue("owner", owner)
+		ctx.SetUserValue("id", "1")
+		h(ctx)
+		return ctx.Response.StatusCode()
+	}
+	assert.Equal(t, fasthttp.StatusOK, request("tom", "tom"))
+	assert.Equal(t, fasthttp.StatusForbidden, request("tom", "jim"))
+	assert.Equal(t, fasthttp.StatusForbidden, request("tom", ""))
+	assert.Equal(t, fasthttp.StatusForbidden, request("tom", "tom/x"))
+
+	ctx := serve(Authorize(ok, e, "update", "orders/tom/1"), "")
+	assert.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+}
@@ -0,0 +1,258 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/errors"
+	"github.com/any-lyu/go.library/jwt"
+)
+
+// AuthClaims the default claims of BearerJWT.
+type AuthClaims struct {
+	UID   string   `json:"uid,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+	Scope string   `json:"scope,omitempty"` // space separated scopes
+	jwt.StandardClaims
+}
+
+// BearerJWT authenticates "Authorization: Bearer <jwt>".
+type BearerJWT struct {
+	// Verify verifies token into claims, e.g. jwt.Verifier.Verify.
+	Verify func(token string, claims jwt.Claims) error
+	// NewClaims returns the claims to decode into, default *AuthClaims.
+	NewClaims func() jwt.Claims
+	// Principal maps verified claims to the principal, the default maps
+	// *AuthClaims, using the subject when uid is empty.
+	Principal func(claims jwt.Claims) *Principal
+	Realm     string
+}
+
+// NewBearerJWT new a bearer authenticator verifying AuthClaims by v.
+func NewBearerJWT(v *jwt.Verifier) *BearerJWT {
+	return &BearerJWT{Verify: v.Verify}
+}
+
+// Authenticate implement Authenticator.
+func (b *BearerJWT) Authenticate(ctx *fasthttp.RequestCtx) (*Principal, error) {
+	header := ctx.Request.Header.Peek("Authorization")
+	if !bytes.HasPrefix(header, []byte("Bearer ")) {
+		return nil, ErrNoCredentials
+	}
+	var claims jwt.Claims = new(AuthClaims)
+	if b.NewClaims != nil {
+		claims = b.NewClaims()
+	}
+	if err := b.Verify(string(header[len("Bearer "):]), claims); err != nil {
+		return nil, err
+	}
+	if b.Principal != nil {
+		return b.Principal(claims), nil
+	}
+	p := &Principal{ID: claims.Standard().Subject, Scheme: "Bearer", Claims: claims}
+	if c, ok := claims.(*AuthClaims); ok {
+		if c.UID != "" {
+			p.ID = c.UID
+		}
+		p.Roles = c.Roles
+		p.Scopes = strings.Fields(c.Scope)
+	}
+	return p, nil
+}
+
+// Challenge implement Authenticator.
+func (b *BearerJWT) Challenge() string {
+	return `Bearer realm="` + realm(b.Realm) + `"`
+}
+
+func realm(r string) string {
+	if r == "" {
+		return "Restricted"
+	}
+	return r
+}
+
+// APIKey authenticates an API key header.
+type APIKey struct {
+	Header string // default X-API-Key
+	// Lookup returns the principal of key, nil for an unknown key.
+	Lookup func(key string) (*Principal, error)
+}
+
+// Authenticate implement Authenticator.
+func (a *APIKey) Authenticate(ctx *fasthttp.RequestCtx) (*Principal, error) {
+	header := a.Header
+	if header == "" {
+		header = "X-API-Key"
+	}
+	key := ctx.Request.Header.Peek(header)
+	if len(key) == 0 {
+		return nil, ErrNoCredentials
+	}
+	p, err := a.Lookup(string(key))
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, errors.ErrToken
+	}
+	return withScheme(p, "ApiKey"), nil
+}
+
+// withScheme returns a copy of p with scheme, p may be shared by the
+// lookups of concurrent requests.
+func withScheme(p *Principal, scheme string) *Principal {
+	cp := *p
+	cp.Scheme = scheme
+	return &cp
+}
+
+// Challenge implement Authenticator, API keys have no challenge.
+func (a *APIKey) Challenge() string {
+	return ""
+}
+
+// CredentialStore checks user passwords for Basic.
+type CredentialStore interface {
+	// Authenticate returns the principal of user, nil for bad credentials.
+	Authenticate(user, password string) (*Principal, error)
+}
+
+// StaticCredentials is a CredentialStore of user to password, for internal
+// tools and tests.
+type StaticCredentials map[string]string
+
+// Authenticate implement CredentialStore.
+func (s StaticCredentials) Authenticate(user, password string) (*Principal, error) {
+	want, ok := s[user]
+	if subtle.ConstantTimeCompare([]byte(want), []byte(password)) != 1 || !ok {
+		return nil, nil
+	}
+	return &Principal{ID: user}, nil
+}
+
+// Basic authenticates HTTP Basic credentials, RFC 7617.
+type Basic struct {
+	Store CredentialStore
+	Realm string
+}
+
+// Authenticate implement Authenticator.
+func (b *Basic) Authenticate(ctx *fasthttp.RequestCtx) (*Principal, error) {
+	header := ctx.Request.Header.Peek("Authorization")
+	if !bytes.HasPrefix(header, []byte("Basic ")) {
+		return nil, ErrNoCredentials
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(header[len("Basic "):]))
+	if err != nil {
+		return nil, err
+	}
+	i := bytes.IndexByte(raw, ':')
+	if i < 0 {
+		return nil, errors.ErrToken
+	}
+	p, err := b.Store.Authenticate(string(raw[:i]), string(raw[i+1:]))
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, errors.ErrToken
+	}
+	return withScheme(p, "Basic"), nil
+}
+
+// Challenge implement Authenticator.
+func (b *Basic) Challenge() string {
+	return `Basic realm="` + realm(b.Realm) + `", charset="UTF-8"`
+}
+
+// HMAC authenticates requests signed by SignRequest:
+//
+//	Authorization: HMAC-SHA256 key=<key id>, ts=<unix seconds>, sig=<hex>
+//
+// sig is the HMAC-SHA256 of "METHOD\nREQUEST_URI\nts\nhex(sha256(body))".
+type HMAC struct {
+	// Secret returns the secret and principal of a key id, nil principal for
+	// an unknown key.
+	Secret func(key string) ([]byte, *Principal, error)
+	// MaxSkew is the accepted difference between ts and now, default 5m.
+	MaxSkew time.Duration
+
+	now func() time.Time
+}
+
+const hmacScheme = "HMAC-SHA256"
+
+// Authenticate implement Authenticator.
+func (a *HMAC) Authenticate(ctx *fasthttp.RequestCtx) (*Principal, error) {
+	header := string(ctx.Request.Header.Peek("Authorization"))
+	if !strings.HasPrefix(header, hmacScheme+" ") {
+		return nil, ErrNoCredentials
+	}
+	params := map[string]string{}
+	for _, kv := range strings.Split(header[len(hmacScheme)+1:], ",") {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			params[strings.TrimSpace(kv[:i])] = strings.TrimSpace(kv[i+1:])
+		}
+	}
+	ts, err := strconv.ParseInt(params["ts"], 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrToken, "hmac: bad ts")
+	}
+	now, skew := time.Now, a.MaxSkew
+	if a.now != nil {
+		now = a.now
+	}
+	if skew <= 0 {
+		skew = 5 * time.Minute
+	}
+	if d := now().Sub(time.Unix(ts, 0)); d > skew || d < -skew {
+		return nil, errors.Wrap(errors.ErrToken, "hmac: ts out of range")
+	}
+	secret, p, err := a.Secret(params["key"])
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, errors.Wrap(errors.ErrToken, "hmac: unknown key")
+	}
+	want := signature(secret, &ctx.Request, params["ts"])
+	if !hmac.Equal([]byte(want), []byte(params["sig"])) {
+		return nil, errors.Wrap(errors.ErrToken, "hmac: bad signature")
+	}
+	return withScheme(p, "HMAC"), nil
+}
+
+// Challenge implement Authenticator.
+func (a *HMAC) Challenge() string {
+	return hmacScheme
+}
+
+// SignRequest signs req for the HMAC authenticator, call it after the
+// method, URI and body are set.
+func SignRequest(req *fasthttp.Request, key string, secret []byte, now time.Time) {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Authorization", hmacScheme+" key="+key+", ts="+ts+", sig="+signature(secret, req, ts))
+}
+
+func signature(secret []byte, req *fasthttp.Request, ts string) string {
+	body := sha256.Sum256(req.Body())
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(req.Header.Method())
+	mac.Write([]byte{'\n'})
+	mac.Write(req.RequestURI())
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(ts))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(hex.EncodeToString(body[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
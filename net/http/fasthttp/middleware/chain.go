@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/logs"
+	"github.com/any-lyu/go.library/rbac"
+	"github.com/any-lyu/go.library/runtime"
+)
+
+// Middleware wraps a handler, it can be passed to Router.Group and Use.
+type Middleware func(fasthttp.RequestHandler) fasthttp.RequestHandler
+
+// Chain composes mws into one middleware, the first is the outermost: a
+// request runs through mws in order then the handler, and the response
+// back through them in reverse.
+//
+//	h := middleware.Chain(
+//		middleware.NewRecover(),
+//		middleware.NewTimeout(3*time.Second),
+//		middleware.NewAuth(middleware.AuthWith(bearer)),
+//	).Then(handler)
+func Chain(mws ...Middleware) Middleware {
+	mws = append([]Middleware(nil), mws...)
+	return func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			h = mws[i](h)
+		}
+		return h
+	}
+}
+
+// Then wraps h by the middleware.
+func (m Middleware) Then(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return m(h)
+}
+
+// NewBase returns BaseHandler as a middleware.
+func NewBase() Middleware {
+	return BaseHandler
+}
+
+type recoverOptions struct {
+	handler func(ctx *fasthttp.RequestCtx, r interface{})
+}
+
+// RecoverOption configures NewRecover.
+type RecoverOption func(*recoverOptions)
+
+// RecoverWith writes the response of a recovered panic r, default 500.
+func RecoverWith(fn func(ctx *fasthttp.RequestCtx, r interface{})) RecoverOption {
+	return func(o *recoverOptions) {
+		o.handler = fn
+	}
+}
+
+// NewRecover new a middleware recovering panics of the handlers it wraps,
+// logging them with their stacks.
+func NewRecover(opts ...RecoverOption) Middleware {
+	o := &recoverOptions{handler: func(ctx *fasthttp.RequestCtx, r interface{}) {
+		ctx.Error(fasthttp.StatusMessage(fasthttp.StatusInternalServerError), fasthttp.StatusInternalServerError)
+	}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			defer func() {
+				if r := recover(); r != nil {
+					logs.Error("middleware: panic recovered: %v\n%s", r, runtime.StackString(runtime.Callers(4)))
+					o.handler(ctx, r)
+				}
+			}()
+			h(ctx)
+		}
+	}
+}
+
+type timeoutOptions struct {
+	msg  string
+	code int
+}
+
+// TimeoutOption configures NewTimeout.
+type TimeoutOption func(*timeoutOptions)
+
+// TimeoutMessage sets the body of timed out responses.
+func TimeoutMessage(msg string) TimeoutOption {
+	return func(o *timeoutOptions) {
+		o.msg = msg
+	}
+}
+
+// TimeoutStatus sets the status of timed out responses, default 408.
+func TimeoutStatus(code int) TimeoutOption {
+	return func(o *timeoutOptions) {
+		o.code = code
+	}
+}
+
+// NewTimeout new a middleware answering requests not handled in timeout,
+// see fasthttp.TimeoutHandler.
+func NewTimeout(timeout time.Duration, opts ...TimeoutOption) Middleware {
+	o := &timeoutOptions{
+		msg:  fasthttp.StatusMessage(fasthttp.StatusRequestTimeout),
+		code: fasthttp.StatusRequestTimeout,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return fasthttp.TimeoutWithCodeHandler(h, timeout, o.msg, o.code)
+	}
+}
+
+type authOptions struct {
+	auths    []Authenticator
+	optional bool
+	scopes   []string
+	roles    []string
+}
+
+// AuthOption configures NewAuth.
+type AuthOption func(*authOptions)
+
+// AuthWith appends authenticators, tried in order.
+func AuthWith(auths ...Authenticator) AuthOption {
+	return func(o *authOptions) {
+		o.auths = append(o.auths, auths...)
+	}
+}
+
+// AuthOptionalCredentials lets requests without credentials through, as
+// AuthOptional.
+func AuthOptionalCredentials() AuthOption {
+	return func(o *authOptions) {
+		o.optional = true
+	}
+}
+
+// AuthScopes requires all the scopes, as RequireScopes.
+func AuthScopes(scopes ...string) AuthOption {
+	return func(o *authOptions) {
+		o.scopes = append(o.scopes, scopes...)
+	}
+}
+
+// AuthRoles requires one of the roles, as RequireRoles.
+func AuthRoles(roles ...string) AuthOption {
+	return func(o *authOptions) {
+		o.roles = append(o.roles, roles...)
+	}
+}
+
+// NewAuth new an authentication middleware, see Auth.
+func NewAuth(opts ...AuthOption) Middleware {
+	o := new(authOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		if len(o.roles) > 0 {
+			h = RequireRoles(h, o.roles...)
+		}
+		if len(o.scopes) > 0 {
+			h = RequireScopes(h, o.scopes...)
+		}
+		return auth(h, o.optional, o.auths)
+	}
+}
+
+// NewAuthorize new a middleware checking the principal against e, see
+// Authorize.
+func NewAuthorize(e *rbac.Enforcer, action, resource string) Middleware {
+	return func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return Authorize(h, e, action, resource)
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+
+	xfasthttp "github.com/any-lyu/go.library/net/http/fasthttp"
+)
+
+func trace(calls *[]string, name string) Middleware {
+	return func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			*calls = append(*calls, ">"+name)
+			h(ctx)
+			*calls = append(*calls, "<"+name)
+		}
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	h := Chain(trace(&calls, "a"), Chain(trace(&calls, "b"), trace(&calls, "c"))).Then(func(ctx *fasthttp.RequestCtx) {
+		calls = append(calls, "h")
+	})
+	h(new(fasthttp.RequestCtx))
+	assert.Equal(t, []string{">a", ">b", ">c", "h", "<c", "<b", "<a"}, calls)
+
+	calls = nil
+	Chain().Then(func(ctx *fasthttp.RequestCtx) { calls = append(calls, "h") })(new(fasthttp.RequestCtx))
+	assert.Equal(t, []string{"h"}, calls)
+}
+
+func TestChainRecoverOutermost(t *testing.T) {
+	var calls []string
+	panics := func(ctx *fasthttp.RequestCtx) { panic("boom") }
+
+	ctx := new(fasthttp.RequestCtx)
+	Chain(NewRecover(), trace(&calls, "a"))(panics)(ctx)
+	assert.Equal(t, fasthttp.StatusInternalServerError, ctx.Response.StatusCode())
+	assert.Equal(t, []string{">a"}, calls, "middlewares inside recover are unwound by the panic")
+
+	ctx = new(fasthttp.RequestCtx)
+	Chain(NewRecover(RecoverWith(func(ctx *fasthttp.RequestCtx, r interface{}) {
+		ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+	})))(panics)(ctx)
+	assert.Equal(t, fasthttp.StatusServiceUnavailable, ctx.Response.StatusCode())
+}
+
+func TestChainAuthBeforeScopes(t *testing.T) {
+	key := &APIKey{Lookup: func(key string) (*Principal, error) {
+		return &Principal{ID: key, Scopes: []string{"read"}, Roles: []string{"staff"}}, nil
+	}}
+	request := func(m Middleware, key string) int {
+		ctx := new(fasthttp.RequestCtx)
+		if key != "" {
+			ctx.Request.Header.Set("X-API-Key", key)
+		}
+		m(ok)(ctx)
+		return ctx.Response.StatusCode()
+	}
+	assert.Equal(t, fasthttp.StatusOK, request(NewAuth(AuthWith(key), AuthScopes("read")), "k"))
+	assert.Equal(t, fasthttp.StatusForbidden, request(NewAuth(AuthWith(key), AuthScopes("write")), "k"))
+	assert.Equal(t, fasthttp.StatusForbidden, request(NewAuth(AuthWith(key), AuthRoles("admin")), "k"))
+	assert.Equal(t, fasthttp.StatusUnauthorized, request(NewAuth(AuthWith(key)), ""))
+	assert.Equal(t, fasthttp.StatusUnauthorized, request(NewAuth(AuthWith(key), AuthOptionalCredentials(), AuthScopes("read")), ""))
+}
+
+func TestChainRateAndTimeout(t *testing.T) {
+	rate := NewRate(RateLimit(1), RateBurst(2))(func(ctx *fasthttp.RequestCtx) {})
+	var codes []int
+	for i := 0; i < 3; i++ {
+		ctx := new(fasthttp.RequestCtx)
+		rate(ctx)
+		codes = append(codes, ctx.Response.StatusCode())
+	}
+	assert.Equal(t, []int{200, 200, 429}, codes)
+
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	go fasthttp.Serve(ln, NewTimeout(10*time.Millisecond, TimeoutMessage("slow"), TimeoutStatus(fasthttp.StatusGatewayTimeout))(func(ctx *fasthttp.RequestCtx) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	c := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+	code, body, err := c.Get(nil, "http://test/")
+	assert.NoError(t, err)
+	assert.Equal(t, fasthttp.StatusGatewayTimeout, code)
+	assert.Equal(t, "slow", string(body))
+}
+
+func TestChainWithRouter(t *testing.T) {
+	var calls []string
+	r := xfasthttp.NewRouter()
+	r.Use(trace(&calls, "router"))
+	r.Group("/api", Chain(trace(&calls, "a"), trace(&calls, "b"))).GET("/x", func(ctx *fasthttp.RequestCtx) {
+		calls = append(calls, "h")
+	})
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.SetRequestURI("/api/x")
+	r.Handler(ctx)
+	assert.Equal(t, []string{">router", ">a", ">b", "h", "<b", "<a", "<router"}, calls)
+}
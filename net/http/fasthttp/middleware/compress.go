@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Compressor compresses response bodies into a content coding.
+type Compressor interface {
+	// Encoding returns the content coding, such as "gzip".
+	Encoding() string
+	// Compress appends src compressed to dst.
+	Compress(dst, src []byte) []byte
+}
+
+type gzipCompressor struct{ level int }
+
+func (gzipCompressor) Encoding() string { return "gzip" }
+
+func (c gzipCompressor) Compress(dst, src []byte) []byte {
+	return fasthttp.AppendGzipBytesLevel(dst, src, c.level)
+}
+
+type deflateCompressor struct{ level int }
+
+func (deflateCompressor) Encoding() string { return "deflate" }
+
+func (c deflateCompressor) Compress(dst, src []byte) []byte {
+	return fasthttp.AppendDeflateBytesLevel(dst, src, c.level)
+}
+
+// Gzip returns a gzip compressor of level, one of the fasthttp.Compress*
+// levels.
+func Gzip(level int) Compressor {
+	return gzipCompressor{level: level}
+}
+
+// Deflate returns a deflate compressor of level, one of the
+// fasthttp.Compress* levels.
+func Deflate(level int) Compressor {
+	return deflateCompressor{level: level}
+}
+
+type compressOptions struct {
+	compressors []Compressor
+	minSize     int
+	types       []string
+}
+
+// CompressOption configures NewCompress.
+type CompressOption func(*compressOptions)
+
+// CompressWith sets the compressors in order of preference, default
+// Gzip(fasthttp.CompressDefaultCompression) then
+// Deflate(fasthttp.CompressDefaultCompression). Other codings, such as br,
+// are added by a Compressor of them.
+func CompressWith(cs ...Compressor) CompressOption {
+	return func(o *compressOptions) {
+		o.compressors = cs
+	}
+}
+
+// CompressMinSize sets the body size under which responses are sent as is,
+// default 1024.
+func CompressMinSize(n int) CompressOption {
+	return func(o *compressOptions) {
+		o.minSize = n
+	}
+}
+
+// CompressTypes sets the media types compressed, a "type/*" entry matches
+// all subtypes, default text/*, application/json, application/xml,
+// application/javascript and image/svg+xml.
+func CompressTypes(types ...string) CompressOption {
+	return func(o *compressOptions) {
+		o.types = types
+	}
+}
+
+// NewCompress new a middleware compressing response bodies in the coding
+// most preferred by the Accept-Encoding header, ties going to the order of
+// CompressWith. Responses of other media types, smaller than
+// CompressMinSize, streamed or already encoded are sent as is. Chain it
+// before NewETag, so the ETag is of the identity body:
+//
+//	h = middleware.Chain(middleware.NewCompress(), middleware.NewETag()).Then(h)
+//
+// A strong ETag of a compressed response is made weak, as the body is not
+// the identity one byte for byte.
+func NewCompress(opts ...CompressOption) Middleware {
+	o := &compressOptions{
+		compressors: []Compressor{Gzip(fasthttp.CompressDefaultCompression), Deflate(fasthttp.CompressDefaultCompression)},
+		minSize:     1024,
+		types:       []string{"text/*", "application/json", "application/xml", "application/javascript", "image/svg+xml"},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			h(ctx)
+			resp := &ctx.Response
+			if !compressible(resp.StatusCode()) || resp.IsBodyStream() || len(resp.Header.Peek("Content-Encoding")) > 0 {
+				return
+			}
+			typ, _, err := mime.ParseMediaType(string(resp.Header.ContentType()))
+			if err != nil || !matchType(o.types, typ) {
+				return
+			}
+			resp.Header.Add("Vary", "Accept-Encoding")
+			body := resp.Body()
+			if len(body) < o.minSize || ctx.IsHead() {
+				return
+			}
+			c := negotiateEncoding(string(ctx.Request.Header.Peek("Accept-Encoding")), o.compressors)
+			if c == nil {
+				return
+			}
+			resp.SetBodyRaw(c.Compress(nil, body))
+			resp.Header.Set("Content-Encoding", c.Encoding())
+			if etag := resp.Header.Peek("ETag"); len(etag) > 0 && !strings.HasPrefix(string(etag), "W/") {
+				resp.Header.Set("ETag", "W/"+string(etag))
+			}
+		}
+	}
+}
+
+func compressible(status int) bool {
+	switch status {
+	case fasthttp.StatusNoContent, fasthttp.StatusPartialContent, fasthttp.StatusNotModified:
+		return false
+	}
+	return status >= fasthttp.StatusOK
+}
+
+func matchType(types []string, typ string) bool {
+	for _, t := range types {
+		if t == typ || strings.HasSuffix(t, "/*") && strings.HasPrefix(typ, t[:len(t)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding returns the compressor of the highest q in header, nil
+// for identity. A coding takes the q of its own entry, else of "*".
+func negotiateEncoding(header string, cs []Compressor) Compressor {
+	if header == "" {
+		return nil
+	}
+	qs := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		coding, q := strings.TrimSpace(part), 1.0
+		if i := strings.IndexByte(coding, ';'); i >= 0 {
+			param := strings.TrimSpace(coding[i+1:])
+			coding = strings.TrimSpace(coding[:i])
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			var err error
+			if q, err = strconv.ParseFloat(param[2:], 64); err != nil {
+				continue
+			}
+		}
+		qs[strings.ToLower(coding)] = q
+	}
+	var (
+		best  Compressor
+		bestQ float64
+	)
+	for _, c := range cs {
+		q, ok := qs[c.Encoding()]
+		if !ok {
+			q = qs["*"]
+		}
+		if q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	return best
+}
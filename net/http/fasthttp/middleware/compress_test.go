@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+type fakeCompressor string
+
+func (c fakeCompressor) Encoding() string { return string(c) }
+
+func (c fakeCompressor) Compress(dst, src []byte) []byte {
+	return append(dst, c+":"...)
+}
+
+func compressed(h fasthttp.RequestHandler, accept string) *fasthttp.RequestCtx {
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.Set("Accept-Encoding", accept)
+	h(ctx)
+	return ctx
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat(`{"id":1}`, 200)
+	typ, etag := "application/json; charset=utf-8", `"v1"`
+	h := NewCompress()(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType(typ)
+		ctx.Response.Header.Set("ETag", etag)
+		ctx.SetBodyString(body)
+	})
+
+	ctx := compressed(h, "gzip, deflate")
+	assert.Equal(t, "gzip", string(ctx.Response.Header.Peek("Content-Encoding")))
+	assert.Equal(t, "Accept-Encoding", string(ctx.Response.Header.Peek("Vary")))
+	assert.Equal(t, `W/"v1"`, string(ctx.Response.Header.Peek("ETag")))
+	b, err := ctx.Response.BodyGunzip()
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(b))
+
+	ctx = compressed(h, "gzip;q=0.5, deflate")
+	assert.Equal(t, "deflate", string(ctx.Response.Header.Peek("Content-Encoding")))
+	b, err = ctx.Response.BodyInflate()
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(b))
+
+	for _, accept := range []string{"", "identity", "gzip;q=0, *;q=0", "br"} {
+		ctx = compressed(h, accept)
+		assert.Empty(t, ctx.Response.Header.Peek("Content-Encoding"), accept)
+		assert.Equal(t, body, string(ctx.Response.Body()), accept)
+	}
+	assert.Equal(t, "gzip", string(compressed(h, "*").Response.Header.Peek("Content-Encoding")))
+
+	typ = "image/png"
+	ctx = compressed(h, "gzip")
+	assert.Empty(t, ctx.Response.Header.Peek("Content-Encoding"))
+	assert.Empty(t, ctx.Response.Header.Peek("Vary"))
+
+	typ, body = "text/plain", "small"
+	ctx = compressed(h, "gzip")
+	assert.Empty(t, ctx.Response.Header.Peek("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", string(ctx.Response.Header.Peek("Vary")), "varies under the min size too")
+}
+
+func TestCompressWith(t *testing.T) {
+	h := NewCompress(CompressWith(fakeCompressor("br"), Gzip(fasthttp.CompressBestSpeed)), CompressMinSize(0))(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType("text/html")
+		ctx.SetBodyString("<p>")
+	})
+	ctx := compressed(h, "gzip, br")
+	assert.Equal(t, "br", string(ctx.Response.Header.Peek("Content-Encoding")), "ties go to the preferred")
+	assert.Equal(t, "br:", string(ctx.Response.Body()))
+	assert.Equal(t, "gzip", string(compressed(h, "gzip, br;q=0.9").Response.Header.Peek("Content-Encoding")))
+}
@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/errors"
+	xtime "github.com/any-lyu/go.library/time"
+)
+
+// CORSConfig cross origin resource sharing config.
+type CORSConfig struct {
+	// AllowOrigins are exact origins such as https://a.com, wildcard
+	// subdomains such as https://*.a.com, or * for any origin, which
+	// can not be used with AllowCredentials.
+	AllowOrigins []string `json:"allow_origins"`
+	// AllowOriginPatterns are regexps matching the whole origin.
+	AllowOriginPatterns []string `json:"allow_origin_patterns"`
+	// AllowMethods default GET, HEAD, POST, PUT, PATCH and DELETE.
+	AllowMethods []string `json:"allow_methods"`
+	// AllowHeaders are the request headers allowed, default the ones asked
+	// by the preflight.
+	AllowHeaders     []string       `json:"allow_headers"`
+	ExposeHeaders    []string       `json:"expose_headers"`
+	AllowCredentials bool           `json:"allow_credentials"`
+	MaxAge           xtime.Duration `json:"max_age"` // preflight cache time, 0 not sent
+}
+
+type cors struct {
+	any      bool
+	exact    map[string]bool
+	suffixes [][2]string // scheme://, .domain of wildcard subdomains
+	patterns []*regexp.Regexp
+	methods  string
+	headers  string
+	expose   string
+	creds    bool
+	maxAge   string
+}
+
+// NewCORS new a CORS middleware. Preflight requests of allowed origins are
+// answered 204 without calling the handler, other requests get the CORS
+// headers after the handler. Requests of other origins get no CORS headers
+// and failed preflights 403.
+func NewCORS(c *CORSConfig) (Middleware, error) {
+	co := &cors{
+		exact:   make(map[string]bool),
+		methods: "GET, HEAD, POST, PUT, PATCH, DELETE",
+		headers: strings.Join(c.AllowHeaders, ", "),
+		expose:  strings.Join(c.ExposeHeaders, ", "),
+		creds:   c.AllowCredentials,
+	}
+	for _, o := range c.AllowOrigins {
+		o = strings.ToLower(o)
+		switch {
+		case o == "*":
+			co.any = true
+		case strings.Contains(o, "://*."):
+			i := strings.Index(o, "://*.")
+			co.suffixes = append(co.suffixes, [2]string{o[:i+3], o[i+4:]})
+		default:
+			co.exact[o] = true
+		}
+	}
+	if co.any && co.creds {
+		// NOTE echoing any origin with credentials lets any site make
+		// credentialed reads.
+		return nil, errors.New("cors: allow_credentials with any origin")
+	}
+	for _, p := range c.AllowOriginPatterns {
+		re, err := regexp.Compile("^(?:" + p + ")$")
+		if err != nil {
+			return nil, errors.Wrapf(err, "cors: bad origin pattern %q", p)
+		}
+		co.patterns = append(co.patterns, re)
+	}
+	if len(c.AllowMethods) > 0 {
+		co.methods = strings.ToUpper(strings.Join(c.AllowMethods, ", "))
+	}
+	if c.MaxAge > 0 {
+		co.maxAge = strconv.FormatInt(int64(time.Duration(c.MaxAge)/time.Second), 10)
+	}
+	return co.handler, nil
+}
+
+func (co *cors) allowed(origin string) bool {
+	if co.any {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if co.exact[origin] {
+		return true
+	}
+	for _, s := range co.suffixes {
+		if strings.HasPrefix(origin, s[0]) && strings.HasSuffix(origin, s[1]) && len(origin) > len(s[0])+len(s[1]) {
+			return true
+		}
+	}
+	for _, re := range co.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowOrigin is the Access-Control-Allow-Origin of origin, * for any
+// origin, so the response does not vary.
+func (co *cors) allowOrigin(origin string) string {
+	if co.any {
+		return "*"
+	}
+	return origin
+}
+
+func (co *cors) handler(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		origin := string(ctx.Request.Header.Peek("Origin"))
+		preflight := ctx.IsOptions() && len(ctx.Request.Header.Peek("Access-Control-Request-Method")) > 0
+		if preflight {
+			co.preflight(ctx, origin)
+			return
+		}
+		h(ctx)
+		if !co.any {
+			ctx.Response.Header.Add("Vary", "Origin")
+		}
+		if origin == "" || !co.allowed(origin) {
+			return
+		}
+		header := &ctx.Response.Header
+		header.Set("Access-Control-Allow-Origin", co.allowOrigin(origin))
+		if co.creds {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if co.expose != "" {
+			header.Set("Access-Control-Expose-Headers", co.expose)
+		}
+	}
+}
+
+func (co *cors) preflight(ctx *fasthttp.RequestCtx, origin string) {
+	header := &ctx.Response.Header
+	header.Add("Vary", "Origin")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	if origin == "" || !co.allowed(origin) {
+		ctx.SetStatusCode(fasthttp.StatusForbidden)
+		return
+	}
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+	header.Set("Access-Control-Allow-Origin", co.allowOrigin(origin))
+	header.Set("Access-Control-Allow-Methods", co.methods)
+	headers := co.headers
+	if headers == "" {
+		headers = string(ctx.Request.Header.Peek("Access-Control-Request-Headers"))
+	}
+	if headers != "" {
+		header.Set("Access-Control-Allow-Headers", headers)
+	}
+	if co.creds {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if co.maxAge != "" {
+		header.Set("Access-Control-Max-Age", co.maxAge)
+	}
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	xtime "github.com/any-lyu/go.library/time"
+)
+
+func corsRequest(h fasthttp.RequestHandler, method, origin string, headers ...string) *fasthttp.RequestCtx {
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.SetMethod(method)
+	if origin != "" {
+		ctx.Request.Header.Set("Origin", origin)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		ctx.Request.Header.Set(headers[i], headers[i+1])
+	}
+	h(ctx)
+	return ctx
+}
+
+func vary(ctx *fasthttp.RequestCtx) []string {
+	var vs []string
+	ctx.Response.Header.VisitAll(func(k, v []byte) {
+		if string(k) == "Vary" {
+			vs = append(vs, string(v))
+		}
+	})
+	return vs
+}
+
+func TestCORS(t *testing.T) {
+	m, err := NewCORS(&CORSConfig{
+		AllowOrigins:        []string{"https://app.example.com", "https://*.example.org"},
+		AllowOriginPatterns: []string{`http://localhost:\d+`},
+		AllowMethods:        []string{"get", "post"},
+		ExposeHeaders:       []string{"X-Request-Id"},
+		AllowCredentials:    true,
+		MaxAge:              xtime.Duration(10 * time.Minute),
+	})
+	assert.NoError(t, err)
+	called := 0
+	h := m(func(ctx *fasthttp.RequestCtx) {
+		called++
+		ctx.Error("nope", fasthttp.StatusBadRequest)
+	})
+
+	for _, origin := range []string{"https://app.example.com", "https://a.b.example.org", "http://localhost:3000"} {
+		ctx := corsRequest(h, "GET", origin)
+		assert.Equal(t, origin, string(ctx.Response.Header.Peek("Access-Control-Allow-Origin")), origin)
+		assert.Equal(t, "true", string(ctx.Response.Header.Peek("Access-Control-Allow-Credentials")))
+		assert.Equal(t, "X-Request-Id", string(ctx.Response.Header.Peek("Access-Control-Expose-Headers")))
+		assert.Equal(t, []string{"Origin"}, vary(ctx))
+	}
+	for _, origin := range []string{"https://evil.com", "https://example.org", "http://app.example.com", "http://localhost:3000.evil.com"} {
+		ctx := corsRequest(h, "GET", origin)
+		assert.Empty(t, ctx.Response.Header.Peek("Access-Control-Allow-Origin"), origin)
+		assert.Equal(t, []string{"Origin"}, vary(ctx))
+	}
+	assert.Equal(t, 7, called)
+
+	ctx := corsRequest(h, "OPTIONS", "https://app.example.com",
+		"Access-Control-Request-Method", "POST", "Access-Control-Request-Headers", "Content-Type, X-Token")
+	assert.Equal(t, 7, called)
+	assert.Equal(t, fasthttp.StatusNoContent, ctx.Response.StatusCode())
+	assert.Equal(t, "GET, POST", string(ctx.Response.Header.Peek("Access-Control-Allow-Methods")))
+	assert.Equal(t, "Content-Type, X-Token", string(ctx.Response.Header.Peek("Access-Control-Allow-Headers")))
+	assert.Equal(t, "600", string(ctx.Response.Header.Peek("Access-Control-Max-Age")))
+	assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, vary(ctx))
+
+	ctx = corsRequest(h, "OPTIONS", "https://evil.com", "Access-Control-Request-Method", "POST")
+	assert.Equal(t, fasthttp.StatusForbidden, ctx.Response.StatusCode())
+	assert.Empty(t, ctx.Response.Header.Peek("Access-Control-Allow-Origin"))
+
+	// plain OPTIONS requests are not preflights
+	corsRequest(h, "OPTIONS", "https://app.example.com")
+	assert.Equal(t, 8, called)
+
+	_, err = NewCORS(&CORSConfig{AllowOriginPatterns: []string{"("}})
+	assert.Error(t, err)
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	m, _ := NewCORS(&CORSConfig{AllowOrigins: []string{"*"}})
+	h := m(func(ctx *fasthttp.RequestCtx) {})
+	ctx := corsRequest(h, "GET", "https://a.com")
+	assert.Equal(t, "*", string(ctx.Response.Header.Peek("Access-Control-Allow-Origin")))
+	assert.Empty(t, vary(ctx))
+
+	_, err := NewCORS(&CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true})
+	assert.Error(t, err)
+
+	ctx = corsRequest(BaseHandler(func(ctx *fasthttp.RequestCtx) {}), "OPTIONS", "https://a.com",
+		"Access-Control-Request-Method", "PUT")
+	assert.Equal(t, fasthttp.StatusNoContent, ctx.Response.StatusCode())
+	assert.Equal(t, "content-type", string(ctx.Response.Header.Peek("Access-Control-Allow-Headers")))
+}
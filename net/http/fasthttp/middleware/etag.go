@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// NewETag new a middleware answering conditional GET and HEAD requests.
+// 200 responses without ETag get a weak one of their body, then a request
+// whose If-None-Match matches the ETag, or without If-None-Match whose
+// If-Modified-Since is not before the Last-Modified of the response, is
+// answered 304 with the headers and no body. Streamed responses, such as
+// files, are sent as is.
+func NewETag() Middleware {
+	return func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			h(ctx)
+			if !ctx.IsGet() && !ctx.IsHead() {
+				return
+			}
+			resp := &ctx.Response
+			if resp.StatusCode() != fasthttp.StatusOK || resp.IsBodyStream() {
+				return
+			}
+			etag := string(resp.Header.Peek("ETag"))
+			if etag == "" {
+				etag = weakETag(resp.Body())
+				resp.Header.Set("ETag", etag)
+			}
+			if notModified(&ctx.Request.Header, &resp.Header, etag) {
+				resp.ResetBody()
+				resp.SetStatusCode(fasthttp.StatusNotModified)
+			}
+		}
+	}
+}
+
+func weakETag(body []byte) string {
+	sum := fnv.New64a()
+	sum.Write(body)
+	return `W/"` + strconv.FormatInt(int64(len(body)), 16) + "-" + strconv.FormatUint(sum.Sum64(), 16) + `"`
+}
+
+func notModified(req *fasthttp.RequestHeader, resp *fasthttp.ResponseHeader, etag string) bool {
+	if inm := req.Peek("If-None-Match"); len(inm) > 0 {
+		return etagMatch(string(inm), etag)
+	}
+	ims := req.Peek("If-Modified-Since")
+	lm := resp.Peek("Last-Modified")
+	if len(ims) == 0 || len(lm) == 0 {
+		return false
+	}
+	since, err := fasthttp.ParseHTTPDate(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := fasthttp.ParseHTTPDate(lm)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// etagMatch compares the If-None-Match list to etag weakly, as RFC 7232.
+func etagMatch(list, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
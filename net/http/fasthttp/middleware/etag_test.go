@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func conditional(h fasthttp.RequestHandler, header, value string) *fasthttp.RequestCtx {
+	ctx := new(fasthttp.RequestCtx)
+	if header != "" {
+		ctx.Request.Header.Set(header, value)
+	}
+	h(ctx)
+	return ctx
+}
+
+func TestETag(t *testing.T) {
+	body := `{"id":1}`
+	h := NewETag()(func(ctx *fasthttp.RequestCtx) {
+		ctx.Response.Header.Set("Cache-Control", "no-cache")
+		ctx.SetBodyString(body)
+	})
+
+	ctx := conditional(h, "", "")
+	etag := string(ctx.Response.Header.Peek("ETag"))
+	assert.True(t, strings.HasPrefix(etag, `W/"`))
+	assert.Equal(t, body, string(ctx.Response.Body()))
+
+	for _, inm := range []string{etag, strings.TrimPrefix(etag, "W/"), `"x", ` + etag, "*"} {
+		ctx = conditional(h, "If-None-Match", inm)
+		assert.Equal(t, fasthttp.StatusNotModified, ctx.Response.StatusCode(), inm)
+		assert.Empty(t, ctx.Response.Body())
+		assert.Equal(t, etag, string(ctx.Response.Header.Peek("ETag")))
+		assert.Equal(t, "no-cache", string(ctx.Response.Header.Peek("Cache-Control")))
+	}
+
+	body = `{"id":2}`
+	ctx = conditional(h, "If-None-Match", etag)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.NotEqual(t, etag, string(ctx.Response.Header.Peek("ETag")))
+}
+
+func TestETagLastModified(t *testing.T) {
+	modified := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	status := fasthttp.StatusOK
+	h := NewETag()(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(status)
+		ctx.Response.Header.Set("ETag", `"v1"`)
+		ctx.Response.Header.SetLastModified(modified)
+		ctx.SetBodyString("data")
+	})
+	date := func(t time.Time) string { return string(fasthttp.AppendHTTPDate(nil, t)) }
+
+	assert.Equal(t, fasthttp.StatusNotModified, conditional(h, "If-Modified-Since", date(modified)).Response.StatusCode())
+	assert.Equal(t, fasthttp.StatusOK, conditional(h, "If-Modified-Since", date(modified.Add(-time.Second))).Response.StatusCode())
+	assert.Equal(t, fasthttp.StatusOK, conditional(h, "If-Modified-Since", "garbage").Response.StatusCode())
+	// If-None-Match takes precedence
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.Set("If-None-Match", `"v0"`)
+	ctx.Request.Header.Set("If-Modified-Since", date(modified))
+	h(ctx)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, `"v1"`, string(ctx.Response.Header.Peek("ETag")), "strong ETags are kept")
+
+	ctx = new(fasthttp.RequestCtx)
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.Header.Set("If-None-Match", `"v1"`)
+	h(ctx)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode(), "unsafe methods are not conditional")
+
+	status = fasthttp.StatusNotFound
+	assert.Equal(t, fasthttp.StatusNotFound, conditional(h, "If-None-Match", `"v1"`).Response.StatusCode())
+}
+
+func TestETagCompress(t *testing.T) {
+	body := strings.Repeat("a", 2048)
+	h := Chain(NewCompress(), NewETag()).Then(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType("text/plain")
+		ctx.SetBodyString(body)
+	})
+	ctx := compressed(h, "gzip")
+	etag := string(ctx.Response.Header.Peek("ETag"))
+	assert.Equal(t, weakETag([]byte(body)), etag, "the ETag is of the identity body")
+
+	ctx = new(fasthttp.RequestCtx)
+	ctx.Request.Header.Set("Accept-Encoding", "gzip")
+	ctx.Request.Header.Set("If-None-Match", etag)
+	h(ctx)
+	assert.Equal(t, fasthttp.StatusNotModified, ctx.Response.StatusCode())
+	assert.Empty(t, ctx.Response.Header.Peek("Content-Encoding"))
+}
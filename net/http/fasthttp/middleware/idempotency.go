@@ -0,0 +1,368 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	redigo "github.com/garyburd/redigo/redis"
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/cache/redis"
+	"github.com/any-lyu/go.library/errors"
+	"github.com/any-lyu/go.library/logs"
+)
+
+const maxIdempotencyKey = 255
+
+// IdempotentResponse a response stored for an idempotency key.
+type IdempotentResponse struct {
+	// Fingerprint of the request, method, path and body, a key reused
+	// for another request is rejected.
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      [][2]string `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// IdempotencyStore locks idempotency keys and stores their responses.
+type IdempotencyStore interface {
+	// Acquire locks key for ttl and returns the lock. It returns the stored
+	// response of a completed key instead, or errors.ErrRepeat if the key is
+	// locked by a request in flight.
+	Acquire(ctx context.Context, key string, ttl time.Duration) (lock string, res *IdempotentResponse, err error)
+	// Complete stores res for key held by lock, for ttl.
+	Complete(ctx context.Context, key, lock string, res *IdempotentResponse, ttl time.Duration) error
+	// Release unlocks key held by lock, the request may be retried.
+	Release(ctx context.Context, key, lock string) error
+}
+
+// NewIdempotencyMemoryStore new a process local idempotency store.
+func NewIdempotencyMemoryStore() IdempotencyStore {
+	return &idempotencyMemory{keys: make(map[string]*idempotencyEntry), now: time.Now}
+}
+
+type idempotencyMemory struct {
+	mu    sync.Mutex
+	keys  map[string]*idempotencyEntry
+	sweep time.Time
+	now   func() time.Time
+}
+
+type idempotencyEntry struct {
+	lock  string
+	res   *IdempotentResponse
+	until time.Time
+}
+
+func (s *idempotencyMemory) Acquire(_ context.Context, key string, ttl time.Duration) (string, *IdempotentResponse, error) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.sweep) > time.Minute {
+		for k, e := range s.keys {
+			if !now.Before(e.until) {
+				delete(s.keys, k)
+			}
+		}
+		s.sweep = now
+	}
+	if e, ok := s.keys[key]; ok && now.Before(e.until) {
+		if e.res != nil {
+			return "", e.res, nil
+		}
+		return "", nil, errors.ErrRepeat
+	}
+	lock := newLock()
+	s.keys[key] = &idempotencyEntry{lock: lock, until: now.Add(ttl)}
+	return lock, nil, nil
+}
+
+func (s *idempotencyMemory) Complete(_ context.Context, key, lock string, res *IdempotentResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.keys[key]; ok && e.lock == lock && e.res == nil {
+		s.keys[key] = &idempotencyEntry{res: res, until: s.now().Add(ttl)}
+	}
+	return nil
+}
+
+func (s *idempotencyMemory) Release(_ context.Context, key, lock string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.keys[key]; ok && e.lock == lock && e.res == nil {
+		delete(s.keys, key)
+	}
+	return nil
+}
+
+// Locks are stored as "lock:" and a random token, responses as json.
+const idempotencyLockPrefix = "lock:"
+
+// _acquireScript KEYS[1] key, ARGV[1] lock, ARGV[2] ttl in milliseconds. It
+// returns the stored value, nil once locked.
+var _acquireScript = redigo.NewScript(1, `
+local v = redis.call("GET", KEYS[1])
+if v then
+	return v
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return false
+`)
+
+// _completeScript KEYS[1] key, ARGV[1] lock, ARGV[2] response, ARGV[3] ttl
+// in milliseconds.
+var _completeScript = redigo.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+	return 1
+end
+return 0
+`)
+
+// _releaseScript KEYS[1] key, ARGV[1] lock.
+var _releaseScript = redigo.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// NewIdempotencyRedisStore new an idempotency store shared by all instances
+// on cache/redis, keys are prefix+key, default prefix "idempotency:".
+func NewIdempotencyRedisStore(client *redis.Client, prefix string) IdempotencyStore {
+	if prefix == "" {
+		prefix = "idempotency:"
+	}
+	return &idempotencyRedis{client: client, prefix: prefix}
+}
+
+type idempotencyRedis struct {
+	client *redis.Client
+	prefix string
+}
+
+func (s *idempotencyRedis) Acquire(ctx context.Context, key string, ttl time.Duration) (string, *IdempotentResponse, error) {
+	conn, err := s.client.Pool.GetContext(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	defer conn.Close()
+
+	lock := idempotencyLockPrefix + newLock()
+	v, err := redigo.Bytes(_acquireScript.Do(conn, s.prefix+key, lock, milliseconds(ttl)))
+	if err == redigo.ErrNil {
+		return lock, nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if bytes.HasPrefix(v, []byte(idempotencyLockPrefix)) {
+		return "", nil, errors.ErrRepeat
+	}
+	res := new(IdempotentResponse)
+	if err = json.Unmarshal(v, res); err != nil {
+		return "", nil, errors.Wrapf(err, "idempotency: decode response of %s", key)
+	}
+	return "", res, nil
+}
+
+func (s *idempotencyRedis) Complete(ctx context.Context, key, lock string, res *IdempotentResponse, ttl time.Duration) error {
+	b, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	conn, err := s.client.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = _completeScript.Do(conn, s.prefix+key, lock, b, milliseconds(ttl))
+	return err
+}
+
+func (s *idempotencyRedis) Release(ctx context.Context, key, lock string) error {
+	conn, err := s.client.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = _releaseScript.Do(conn, s.prefix+key, lock)
+	return err
+}
+
+func milliseconds(d time.Duration) int64 {
+	if ms := int64(d / time.Millisecond); ms > 0 {
+		return ms
+	}
+	return 1
+}
+
+func newLock() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+type idempotencyOptions struct {
+	ttl     time.Duration
+	lockTTL time.Duration
+	scope   KeyFunc
+}
+
+// IdempotencyOption configures NewIdempotency.
+type IdempotencyOption func(*idempotencyOptions)
+
+// IdempotencyTTL sets how long responses are replayed, default 24h.
+func IdempotencyTTL(d time.Duration) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.ttl = d
+	}
+}
+
+// IdempotencyLockTTL sets how long a key is locked by a request in flight,
+// default 1m. It should be longer than the slowest request, the lock of a
+// crashed instance expires after it.
+func IdempotencyLockTTL(d time.Duration) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.lockTTL = d
+	}
+}
+
+// IdempotencyScope sets the scope of keys, default the principal stored by
+// Auth, so clients can not replay the responses of others.
+func IdempotencyScope(fn KeyFunc) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.scope = fn
+	}
+}
+
+// NewIdempotency new a middleware honouring the Idempotency-Key header of
+// POST, PUT, PATCH and DELETE requests. The first request of a key locks it
+// in store and its response, status, headers and body, is stored and
+// replayed with Idempotent-Replayed: true for the duplicates. Duplicates in
+// flight are answered 409, keys reused for another method, path or body
+// 422. 5xx, streamed and panicking responses are not stored, the key is
+// released for the client to retry. Requests fail with 503 while the store
+// is unavailable.
+//
+//	m := middleware.NewIdempotency(middleware.NewIdempotencyRedisStore(client, ""))
+func NewIdempotency(store IdempotencyStore, opts ...IdempotencyOption) Middleware {
+	o := &idempotencyOptions{ttl: 24 * time.Hour, lockTTL: time.Minute, scope: scopeByUser}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			header := ctx.Request.Header.Peek("Idempotency-Key")
+			if len(header) == 0 || !unsafeMethod(ctx.Method()) {
+				h(ctx)
+				return
+			}
+			if len(header) > maxIdempotencyKey {
+				ctx.Error("Idempotency-Key too long", fasthttp.StatusBadRequest)
+				return
+			}
+			key := o.scope(ctx) + "|" + string(header)
+			fp := fingerprint(ctx)
+			lock, res, err := store.Acquire(ctx, key, o.lockTTL)
+			switch {
+			case err == errors.ErrRepeat:
+				ctx.Error("request with the same Idempotency-Key in progress", fasthttp.StatusConflict)
+				ctx.Response.Header.Set("Retry-After", "1")
+				return
+			case err != nil:
+				logs.Error("middleware: idempotency acquire %s error(%v)", key, err)
+				ctx.Error(fasthttp.StatusMessage(fasthttp.StatusServiceUnavailable), fasthttp.StatusServiceUnavailable)
+				ctx.Response.Header.Set("Retry-After", "1")
+				return
+			case res != nil:
+				if res.Fingerprint != fp {
+					ctx.Error("Idempotency-Key reused for another request", fasthttp.StatusUnprocessableEntity)
+					return
+				}
+				replay(ctx, res)
+				return
+			}
+			returned := false
+			defer func() {
+				if !returned || ctx.Response.StatusCode() >= fasthttp.StatusInternalServerError || ctx.Response.IsBodyStream() {
+					if err := store.Release(ctx, key, lock); err != nil {
+						logs.Error("middleware: idempotency release %s error(%v)", key, err)
+					}
+					return
+				}
+				if err := store.Complete(ctx, key, lock, stored(ctx, fp), o.ttl); err != nil {
+					logs.Error("middleware: idempotency complete %s error(%v)", key, err)
+				}
+			}()
+			h(ctx)
+			returned = true
+		}
+	}
+}
+
+func scopeByUser(ctx *fasthttp.RequestCtx) string {
+	if p, ok := PrincipalFrom(ctx); ok {
+		return "user:" + p.ID
+	}
+	return ""
+}
+
+func unsafeMethod(method []byte) bool {
+	switch string(method) {
+	case fasthttp.MethodPost, fasthttp.MethodPut, fasthttp.MethodPatch, fasthttp.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func fingerprint(ctx *fasthttp.RequestCtx) string {
+	sum := sha256.New()
+	sum.Write(ctx.Method())
+	sum.Write([]byte{' '})
+	sum.Write(ctx.Path())
+	sum.Write([]byte{'\n'})
+	sum.Write(ctx.PostBody())
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// replayedHeaders are set by the server for each response.
+var replayedHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Date":              true,
+	"Server":            true,
+	"Connection":        true,
+	"Transfer-Encoding": true,
+}
+
+func stored(ctx *fasthttp.RequestCtx, fp string) *IdempotentResponse {
+	res := &IdempotentResponse{Fingerprint: fp, Status: ctx.Response.StatusCode(), Body: append([]byte(nil), ctx.Response.Body()...)}
+	ctx.Response.Header.VisitAll(func(k, v []byte) {
+		if !replayedHeaders[string(k)] {
+			res.Header = append(res.Header, [2]string{string(k), string(v)})
+		}
+	})
+	return res
+}
+
+func replay(ctx *fasthttp.RequestCtx, res *IdempotentResponse) {
+	for _, kv := range res.Header {
+		if strings.EqualFold(kv[0], "Content-Type") {
+			ctx.SetContentType(kv[1])
+			continue
+		}
+		ctx.Response.Header.Add(kv[0], kv[1])
+	}
+	ctx.Response.Header.Set("Idempotent-Replayed", "true")
+	ctx.SetStatusCode(res.Status)
+	ctx.SetBody(res.Body)
+}
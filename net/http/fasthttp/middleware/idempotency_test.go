@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/cache/redis"
+	"github.com/any-lyu/go.library/cache/redis/redistest"
+)
+
+func post(h fasthttp.RequestHandler, key, body string) *fasthttp.RequestCtx {
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.SetRequestURI("/payments")
+	if key != "" {
+		ctx.Request.Header.Set("Idempotency-Key", key)
+	}
+	ctx.Request.SetBodyString(body)
+	h(ctx)
+	return ctx
+}
+
+func testIdempotency(t *testing.T, store IdempotencyStore) {
+	calls := 0
+	var blocked func()
+	h := NewIdempotency(store)(func(ctx *fasthttp.RequestCtx) {
+		calls++
+		if blocked != nil {
+			blocked()
+		}
+		if string(ctx.PostBody()) == "fail" {
+			ctx.Error("boom", fasthttp.StatusInternalServerError)
+			return
+		}
+		ctx.SetStatusCode(fasthttp.StatusCreated)
+		ctx.SetContentType("application/json")
+		ctx.Response.Header.Set("Location", "/payments/1")
+		ctx.SetBodyString(`{"id":1}`)
+	})
+
+	ctx := post(h, "k1", "pay")
+	assert.Equal(t, fasthttp.StatusCreated, ctx.Response.StatusCode())
+	assert.Empty(t, ctx.Response.Header.Peek("Idempotent-Replayed"))
+
+	ctx = post(h, "k1", "pay")
+	assert.Equal(t, 1, calls, "duplicates are replayed")
+	assert.Equal(t, fasthttp.StatusCreated, ctx.Response.StatusCode())
+	assert.Equal(t, `{"id":1}`, string(ctx.Response.Body()))
+	assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, "/payments/1", string(ctx.Response.Header.Peek("Location")))
+	assert.Equal(t, "true", string(ctx.Response.Header.Peek("Idempotent-Replayed")))
+
+	assert.Equal(t, fasthttp.StatusUnprocessableEntity, post(h, "k1", "other").Response.StatusCode())
+	assert.Equal(t, 1, calls)
+
+	// requests without key are not deduplicated
+	post(h, "", "pay")
+	post(h, "", "pay")
+	assert.Equal(t, 3, calls)
+
+	// 5xx release the key
+	assert.Equal(t, fasthttp.StatusInternalServerError, post(h, "k2", "fail").Response.StatusCode())
+	assert.Equal(t, fasthttp.StatusInternalServerError, post(h, "k2", "fail").Response.StatusCode())
+	assert.Equal(t, 5, calls)
+
+	// duplicates in flight are rejected
+	var dup *fasthttp.RequestCtx
+	blocked = func() {
+		blocked = nil
+		dup = post(h, "k3", "pay")
+	}
+	assert.Equal(t, fasthttp.StatusCreated, post(h, "k3", "pay").Response.StatusCode())
+	assert.Equal(t, fasthttp.StatusConflict, dup.Response.StatusCode())
+	assert.Equal(t, "1", string(dup.Response.Header.Peek("Retry-After")))
+	assert.Equal(t, 6, calls)
+}
+
+func TestIdempotencyMemory(t *testing.T) {
+	testIdempotency(t, NewIdempotencyMemoryStore())
+}
+
+func TestIdempotencyPanic(t *testing.T) {
+	calls := 0
+	h := NewIdempotency(NewIdempotencyMemoryStore())(func(ctx *fasthttp.RequestCtx) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+	})
+	assert.Panics(t, func() { post(h, "k", "pay") })
+	assert.Equal(t, fasthttp.StatusOK, post(h, "k", "pay").Response.StatusCode(), "the key is released")
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	calls := 0
+	h := NewIdempotency(NewIdempotencyMemoryStore())(func(ctx *fasthttp.RequestCtx) { calls++ })
+	for _, id := range []string{"1", "2", "1"} {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.Header.Set("Idempotency-Key", "k")
+		ctx.SetUserValue(principalKey, &Principal{ID: id})
+		h(ctx)
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyRedis(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		keys = map[string]string{}
+	)
+	// emulates the scripts, EVAL args are script, numkeys, key and ARGV
+	srv := redistest.NewServer(func(cmd string, args []string) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		switch cmd {
+		case "EVALSHA":
+			return redistest.Error("NOSCRIPT No matching script")
+		case "EVAL":
+			script, key, argv := args[0], args[2], args[3:]
+			if !strings.HasPrefix(key, "idempotency:") {
+				return redistest.Error("ERR bad key")
+			}
+			v, ok := keys[key]
+			switch {
+			case strings.Contains(script, "DEL"):
+				if ok && v == argv[0] {
+					delete(keys, key)
+					return 1
+				}
+				return 0
+			case strings.Contains(script, "ARGV[3]"):
+				if ok && v == argv[0] {
+					keys[key] = argv[1]
+					return 1
+				}
+				return 0
+			default:
+				if ok {
+					return v
+				}
+				keys[key] = argv[0]
+				return nil
+			}
+		}
+		return redistest.Error("ERR unexpected command")
+	})
+	defer srv.Close()
+	testIdempotency(t, NewIdempotencyRedisStore(&redis.Client{Pool: srv.Pool()}, ""))
+}
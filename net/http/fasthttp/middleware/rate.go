@@ -1,24 +1,335 @@
 package middleware
 
 import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/valyala/fasthttp"
-	"golang.org/x/time/rate"
+	xrate "golang.org/x/time/rate"
 
 	"github.com/any-lyu/go.library/errors"
+	xfasthttp "github.com/any-lyu/go.library/net/http/fasthttp"
+	"github.com/any-lyu/go.library/net/ip"
+	"github.com/any-lyu/go.library/rate"
 )
 
 // RateHandler 限流
 // bursts of at most b tokens.
+//
+// Deprecated: use NewRate, which supports keys and rate limit headers.
 func RateHandler(h fasthttp.RequestHandler, b int) fasthttp.RequestHandler {
+	limiter := xrate.NewLimiter(xrate.Every(time.Second), b)
 	return func(ctx *fasthttp.RequestCtx) {
-		limiter := rate.NewLimiter(rate.Every(time.Second), b)
 		if limiter.Allow() {
 			h(ctx)
 			return
 		}
 		ctx.Error(errors.ErrSystemBusy.Error(), errors.ErrCode(errors.ErrSystemBusy))
+	}
+}
+
+// RateResult the decision of a RateLimiter on a request.
+type RateResult struct {
+	Allowed    bool
+	Limit      int           // X-RateLimit-Limit, no rate limit headers if 0
+	Remaining  int           // X-RateLimit-Remaining
+	ResetAfter time.Duration // X-RateLimit-Reset, until the limit is fully available
+	RetryAfter time.Duration // Retry-After of rejected requests
+	// Done reports the outcome of an allowed request, may be nil.
+	Done func(rate.Op)
+}
+
+// RateLimiter decides the requests of one key.
+type RateLimiter interface {
+	Take(ctx context.Context) *RateResult
+}
+
+// KeyFunc returns the rate limit key of a request.
+type KeyFunc func(ctx *fasthttp.RequestCtx) string
+
+// KeyByIP keys by client ip, X-Forwarded-For is believed from the trusted
+// proxies only, see ip.ClientIP.
+func KeyByIP(trusted ...*net.IPNet) KeyFunc {
+	return func(ctx *fasthttp.RequestCtx) string {
+		return "ip:" + clientIP(ctx, trusted)
+	}
+}
+
+func clientIP(ctx *fasthttp.RequestCtx, trusted []*net.IPNet) string {
+	return ip.ClientIP(ctx.RemoteAddr().String(), string(ctx.Request.Header.Peek("X-Forwarded-For")), trusted)
+}
+
+// KeyByUser keys by the principal stored by Auth, anonymous requests are
+// keyed by client ip.
+func KeyByUser(trusted ...*net.IPNet) KeyFunc {
+	byIP := KeyByIP(trusted...)
+	return func(ctx *fasthttp.RequestCtx) string {
+		if p, ok := PrincipalFrom(ctx); ok {
+			return "user:" + p.ID
+		}
+		return byIP(ctx)
+	}
+}
+
+// KeyByRoute keys by method and route template, or path if no route
+// matched.
+func KeyByRoute() KeyFunc {
+	return func(ctx *fasthttp.RequestCtx) string {
+		route := xfasthttp.RouteTemplate(ctx)
+		if route == "" {
+			route = string(ctx.Path())
+		}
+		return "route:" + string(ctx.Method()) + " " + route
+	}
+}
+
+// Keys combines keys, e.g. Keys(KeyByUser(), KeyByRoute()) limits each user
+// on each route.
+func Keys(fns ...KeyFunc) KeyFunc {
+	return func(ctx *fasthttp.RequestCtx) string {
+		key := ""
+		for i, fn := range fns {
+			if i > 0 {
+				key += "|"
+			}
+			key += fn(ctx)
+		}
+		return key
+	}
+}
+
+// TokenBucket returns a backend of token buckets refilled at limit per
+// second up to burst, one per key.
+func TokenBucket(limit xrate.Limit, burst int) func(key string) RateLimiter {
+	return func(string) RateLimiter {
+		return &tokenBucket{limit: float64(limit), burst: burst, tokens: float64(burst), now: time.Now}
+	}
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	limit  float64
+	burst  int
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func (b *tokenBucket) Take(context.Context) *RateResult {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens = math.Min(float64(b.burst), b.tokens+now.Sub(b.last).Seconds()*b.limit)
+	}
+	b.last = now
+	res := &RateResult{Limit: b.burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = b.after(1 - b.tokens)
+	}
+	res.Remaining = int(b.tokens)
+	res.ResetAfter = b.after(float64(b.burst) - b.tokens)
+	return res
+}
+
+func (b *tokenBucket) after(tokens float64) time.Duration {
+	if b.limit <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / b.limit * float64(time.Second))
+}
+
+// Adaptive returns a backend sharing l among all keys, such as a
+// rate/limit.Limiter shedding load by latency. Rejected requests are
+// answered without rate limit headers.
+func Adaptive(l rate.Limiter) func(key string) RateLimiter {
+	a := &adaptive{l: l}
+	return func(string) RateLimiter {
+		return a
+	}
+}
+
+type adaptive struct {
+	l rate.Limiter
+}
+
+func (a *adaptive) Take(ctx context.Context) *RateResult {
+	done, err := a.l.Allow(ctx)
+	if err != nil {
+		return &RateResult{RetryAfter: time.Second}
+	}
+	return &RateResult{Allowed: true, Done: done}
+}
+
+type rateOptions struct {
+	key       KeyFunc
+	backend   func(key string) RateLimiter
+	limit     xrate.Limit
+	burst     int
+	idle      time.Duration
+	onLimited fasthttp.RequestHandler
+}
+
+// RateOption configures NewRate.
+type RateOption func(*rateOptions)
+
+// RateLimit sets the requests per second of the default token bucket
+// backend, default 1.
+func RateLimit(r xrate.Limit) RateOption {
+	return func(o *rateOptions) {
+		o.limit = r
+	}
+}
+
+// RateBurst sets the burst of the default token bucket backend, default 1.
+func RateBurst(b int) RateOption {
+	return func(o *rateOptions) {
+		o.burst = b
+	}
+}
+
+// RateKey sets the key of requests, default one key for all requests.
+func RateKey(fn KeyFunc) RateOption {
+	return func(o *rateOptions) {
+		o.key = fn
+	}
+}
+
+// RateBackend sets the limiter made for each key, default TokenBucket of
+// RateLimit and RateBurst.
+func RateBackend(fn func(key string) RateLimiter) RateOption {
+	return func(o *rateOptions) {
+		o.backend = fn
+	}
+}
+
+// RateIdle sets how long a key is kept after its last request, default 10m.
+func RateIdle(d time.Duration) RateOption {
+	return func(o *rateOptions) {
+		o.idle = d
+	}
+}
+
+// RateOnLimited sets the handler of rejected requests, default 429.
+func RateOnLimited(h fasthttp.RequestHandler) RateOption {
+	return func(o *rateOptions) {
+		o.onLimited = h
+	}
+}
+
+// NewRate new a rate limit middleware keeping a limiter per key across
+// requests. Responses carry X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset, rejected ones Retry-After too.
+//
+//	m := middleware.NewRate(
+//		middleware.RateKey(middleware.Keys(middleware.KeyByUser(), middleware.KeyByRoute())),
+//		middleware.RateLimit(10), middleware.RateBurst(20),
+//	)
+func NewRate(opts ...RateOption) Middleware {
+	o := &rateOptions{limit: xrate.Every(time.Second), burst: 1, idle: 10 * time.Minute}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.backend == nil {
+		o.backend = TokenBucket(o.limit, o.burst)
+	}
+	keys := newRateKeys(o.backend, o.idle)
+	return func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			key := ""
+			if o.key != nil {
+				key = o.key(ctx)
+			}
+			res := keys.get(key).Take(ctx)
+			if !res.Allowed {
+				if o.onLimited != nil {
+					o.onLimited(ctx)
+				} else {
+					ctx.Error(fasthttp.StatusMessage(fasthttp.StatusTooManyRequests), fasthttp.StatusTooManyRequests)
+				}
+				setRateHeaders(ctx, res)
+				if res.RetryAfter > 0 {
+					ctx.Response.Header.Set("Retry-After", strconv.FormatInt(ceilSeconds(res.RetryAfter), 10))
+				}
+				return
+			}
+			setRateHeaders(ctx, res)
+			if res.Done != nil {
+				returned := false
+				// NOTE a panicking handler is reported as Ignore, deferred so
+				// it does not leak the limiter slots.
+				defer func() {
+					op := rate.Ignore
+					if returned && ctx.Response.StatusCode() < fasthttp.StatusInternalServerError {
+						op = rate.Success
+					}
+					res.Done(op)
+				}()
+				h(ctx)
+				returned = true
+				return
+			}
+			h(ctx)
+		}
+	}
+}
+
+func setRateHeaders(ctx *fasthttp.RequestCtx, res *RateResult) {
+	if res.Limit == 0 {
 		return
 	}
+	ctx.Response.Header.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	ctx.Response.Header.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	ctx.Response.Header.Set("X-RateLimit-Reset", strconv.FormatInt(ceilSeconds(res.ResetAfter), 10))
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
+
+// rateKeys the limiters of keys, evicting keys idle longer than idle.
+type rateKeys struct {
+	mu      sync.Mutex
+	backend func(key string) RateLimiter
+	idle    time.Duration
+	keys    map[string]*rateKey
+	swept   time.Time
+	now     func() time.Time
+}
+
+type rateKey struct {
+	limiter RateLimiter
+	seen    time.Time
+}
+
+func newRateKeys(backend func(key string) RateLimiter, idle time.Duration) *rateKeys {
+	return &rateKeys{backend: backend, idle: idle, keys: make(map[string]*rateKey), now: time.Now}
+}
+
+func (k *rateKeys) get(key string) RateLimiter {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := k.now()
+	if now.Sub(k.swept) > k.idle/2 {
+		for key, rk := range k.keys {
+			if now.Sub(rk.seen) > k.idle {
+				delete(k.keys, key)
+			}
+		}
+		k.swept = now
+	}
+	rk, ok := k.keys[key]
+	if !ok {
+		rk = &rateKey{limiter: k.backend(key)}
+		k.keys[key] = rk
+	}
+	rk.seen = now
+	return rk.limiter
 }
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/errors"
+	"github.com/any-lyu/go.library/net/ip"
+	"github.com/any-lyu/go.library/rate"
+)
+
+func fromIP(h fasthttp.RequestHandler, addr, xff string) *fasthttp.RequestCtx {
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Init(&fasthttp.Request{}, &net.TCPAddr{IP: net.ParseIP(addr), Port: 1234}, nil)
+	if xff != "" {
+		ctx.Request.Header.Set("X-Forwarded-For", xff)
+	}
+	h(ctx)
+	return ctx
+}
+
+func TestRateTokenBucket(t *testing.T) {
+	now := time.Unix(1000, 0)
+	backend := func(key string) RateLimiter {
+		b := TokenBucket(2, 3)(key).(*tokenBucket)
+		b.now = func() time.Time { return now }
+		return b
+	}
+	trusted, _ := ip.ParseNets("10.0.0.0/8")
+	h := NewRate(RateKey(KeyByIP(trusted...)), RateBackend(backend))(func(ctx *fasthttp.RequestCtx) {})
+
+	var codes []int
+	for i := 0; i < 4; i++ {
+		codes = append(codes, fromIP(h, "1.1.1.1", "").Response.StatusCode())
+	}
+	assert.Equal(t, []int{200, 200, 200, 429}, codes)
+
+	ctx := fromIP(h, "1.1.1.1", "")
+	assert.Equal(t, "3", string(ctx.Response.Header.Peek("X-RateLimit-Limit")))
+	assert.Equal(t, "0", string(ctx.Response.Header.Peek("X-RateLimit-Remaining")))
+	assert.Equal(t, "2", string(ctx.Response.Header.Peek("X-RateLimit-Reset")))
+	assert.Equal(t, "1", string(ctx.Response.Header.Peek("Retry-After")))
+
+	// other clients have their own bucket, proxies forward the client ip
+	assert.Equal(t, 200, fromIP(h, "2.2.2.2", "").Response.StatusCode())
+	assert.Equal(t, 200, fromIP(h, "10.0.0.1", "3.3.3.3").Response.StatusCode())
+	assert.Equal(t, 429, fromIP(h, "10.0.0.1", "1.1.1.1").Response.StatusCode())
+	// spoofed headers from untrusted clients are ignored
+	assert.Equal(t, 429, fromIP(h, "1.1.1.1", "4.4.4.4").Response.StatusCode())
+
+	now = now.Add(500 * time.Millisecond)
+	ctx = fromIP(h, "1.1.1.1", "")
+	assert.Equal(t, 200, ctx.Response.StatusCode())
+	assert.Equal(t, "0", string(ctx.Response.Header.Peek("X-RateLimit-Remaining")))
+}
+
+func TestRateKeysEviction(t *testing.T) {
+	made := 0
+	keys := newRateKeys(func(string) RateLimiter {
+		made++
+		return TokenBucket(1, 1)("")
+	}, time.Minute)
+	now := time.Unix(1000, 0)
+	keys.now = func() time.Time { return now }
+
+	a := keys.get("a")
+	keys.get("b")
+	assert.True(t, a == keys.get("a"))
+	now = now.Add(50 * time.Second)
+	keys.get("a")
+	now = now.Add(50 * time.Second)
+	keys.get("a")
+	assert.Len(t, keys.keys, 1, "b is evicted")
+	assert.True(t, a == keys.get("a"))
+	assert.Equal(t, 2, made)
+}
+
+func TestRateKeys(t *testing.T) {
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Init(&fasthttp.Request{}, &net.TCPAddr{IP: net.ParseIP("1.1.1.1")}, nil)
+	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.SetRequestURI("/orders/7")
+	key := Keys(KeyByUser(), KeyByRoute())
+	assert.Equal(t, "ip:1.1.1.1|route:GET /orders/7", key(ctx))
+	ctx.SetUserValue(principalKey, &Principal{ID: "42"})
+	ctx.SetUserValue("router.route", "/orders/:id")
+	assert.Equal(t, "user:42|route:GET /orders/:id", key(ctx))
+}
+
+type fakeLimiter struct {
+	allow bool
+	ops   []rate.Op
+}
+
+func (l *fakeLimiter) Allow(ctx context.Context) (func(rate.Op), error) {
+	if !l.allow {
+		return nil, errors.ErrLimitExceed
+	}
+	return func(op rate.Op) { l.ops = append(l.ops, op) }, nil
+}
+
+func TestRateAdaptive(t *testing.T) {
+	l := &fakeLimiter{allow: true}
+	status := fasthttp.StatusOK
+	h := NewRate(RateKey(KeyByIP()), RateBackend(Adaptive(l)))(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(status)
+	})
+	fromIP(h, "1.1.1.1", "")
+	status = fasthttp.StatusBadGateway
+	fromIP(h, "2.2.2.2", "")
+	assert.Equal(t, []rate.Op{rate.Success, rate.Ignore}, l.ops)
+
+	panicking := NewRate(RateBackend(Adaptive(l)))(func(ctx *fasthttp.RequestCtx) { panic("boom") })
+	assert.Panics(t, func() { fromIP(panicking, "3.3.3.3", "") })
+	assert.Equal(t, []rate.Op{rate.Success, rate.Ignore, rate.Ignore}, l.ops)
+
+	l.allow = false
+	ctx := fromIP(h, "1.1.1.1", "")
+	assert.Equal(t, fasthttp.StatusTooManyRequests, ctx.Response.StatusCode())
+	assert.Equal(t, "1", string(ctx.Response.Header.Peek("Retry-After")))
+	assert.Empty(t, ctx.Response.Header.Peek("X-RateLimit-Limit"))
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/rbac"
+)
+
+// Authorize lets requests through only when the principal stored by Auth
+// may do action on resource, 401 without a principal and 403 when denied.
+// "{name}" in resource is replaced by the string user value name, e.g. a
+// route param: Authorize(h, e, "update", "orders/{id}"). Requests whose
+// value is missing, empty or contains "/" are denied, as they would shift
+// the segments of the resource.
+func Authorize(h fasthttp.RequestHandler, e *rbac.Enforcer, action, resource string) fasthttp.RequestHandler {
+	return AuthorizeFunc(h, e, func(ctx *fasthttp.RequestCtx) *rbac.Request {
+		res, ok := expand(ctx, resource)
+		if !ok {
+			return nil
+		}
+		return &rbac.Request{Action: action, Resource: res}
+	})
+}
+
+// AuthorizeFunc is like Authorize with the request built by fn, the subject
+// is filled from the principal when fn leaves it nil. A nil request is
+// denied.
+func AuthorizeFunc(h fasthttp.RequestHandler, e *rbac.Enforcer, fn func(ctx *fasthttp.RequestCtx) *rbac.Request) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		p, ok := PrincipalFrom(ctx)
+		if !ok {
+			ctx.Error(fasthttp.StatusMessage(fasthttp.StatusUnauthorized), fasthttp.StatusUnauthorized)
+			return
+		}
+		req := fn(ctx)
+		if req != nil && req.Subject == nil {
+			req.Subject = &rbac.Subject{ID: p.ID, Roles: p.Roles}
+		}
+		if req == nil || !e.Allowed(req) {
+			ctx.Error(fasthttp.StatusMessage(fasthttp.StatusForbidden), fasthttp.StatusForbidden)
+			return
+		}
+		h(ctx)
+	}
+}
+
+func expand(ctx *fasthttp.RequestCtx, resource string) (string, bool) {
+	if strings.IndexByte(resource, '{') < 0 {
+		return resource, true
+	}
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(resource, '{')
+		j := strings.IndexByte(resource, '}')
+		if i < 0 || j < i {
+			b.WriteString(resource)
+			return b.String(), true
+		}
+		b.WriteString(resource[:i])
+		v, _ := ctx.UserValue(resource[i+1 : j]).(string)
+		if v == "" || strings.IndexByte(v, '/') >= 0 {
+			return "", false
+		}
+		b.WriteString(v)
+		resource = resource[j+1:]
+	}
+}
@@ -0,0 +1,218 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/rate"
+)
+
+// Priority the shedding class of a request.
+type Priority int
+
+const (
+	// Low requests are shed first, at half the in-flight limit.
+	Low Priority = iota - 1
+	// Normal requests, the default.
+	Normal
+	// High requests are shed last, at 1.5 times the in-flight limit.
+	High
+	// Critical requests are never shed.
+	Critical
+)
+
+var priorityFactor = map[Priority]float64{Low: 0.5, Normal: 1, High: 1.5}
+
+type shedOptions struct {
+	threshold uint64
+	usage     func() uint64
+	dropAfter time.Duration
+	priority  func(ctx *fasthttp.RequestCtx) Priority
+	onShed    fasthttp.RequestHandler
+}
+
+// ShedOption configures NewShed.
+type ShedOption func(*shedOptions)
+
+// ShedCPU sheds requests while cpu usage, in per mille as cpu.Usage, is
+// over threshold and the requests in flight exceed what the server passed
+// at its best latency in the last second, as BBR.
+func ShedCPU(threshold uint64, usage func() uint64) ShedOption {
+	return func(o *shedOptions) {
+		o.threshold, o.usage = threshold, usage
+	}
+}
+
+// ShedDropAfter reports requests slower than d as rate.Drop to the
+// limiter, making it shrink its limit, default never.
+func ShedDropAfter(d time.Duration) ShedOption {
+	return func(o *shedOptions) {
+		o.dropAfter = d
+	}
+}
+
+// ShedPriority sets the priority of requests, default Normal.
+func ShedPriority(fn func(ctx *fasthttp.RequestCtx) Priority) ShedOption {
+	return func(o *shedOptions) {
+		o.priority = fn
+	}
+}
+
+// ShedOnShed sets the handler of shed requests, default 503.
+func ShedOnShed(h fasthttp.RequestHandler) ShedOption {
+	return func(o *shedOptions) {
+		o.onShed = h
+	}
+}
+
+// NewShed new a load shedding middleware. Requests are admitted by l, such
+// as rate/limit.Limiter estimating the concurrency by latency, then by the
+// cpu check if ShedCPU is set. Admitted requests are reported to l as
+// rate.Ignore on 5xx, rate.Drop when slower than ShedDropAfter and
+// rate.Success otherwise. Critical requests skip both checks.
+//
+//	m := middleware.NewShed(limit.New(nil),
+//		middleware.ShedCPU(800, cpu.Usage),
+//		middleware.ShedPriority(func(ctx *fasthttp.RequestCtx) middleware.Priority {
+//			if bytes.HasPrefix(ctx.Path(), []byte("/pay/")) {
+//				return middleware.Critical
+//			}
+//			return middleware.Normal
+//		}),
+//	)
+func NewShed(l rate.Limiter, opts ...ShedOption) Middleware {
+	o := &shedOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.onShed == nil {
+		o.onShed = func(ctx *fasthttp.RequestCtx) {
+			ctx.Error(fasthttp.StatusMessage(fasthttp.StatusServiceUnavailable), fasthttp.StatusServiceUnavailable)
+			ctx.Response.Header.Set("Retry-After", strconv.Itoa(1))
+		}
+	}
+	b := newBBR(time.Second, 10, time.Now)
+	return func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			p := Normal
+			if o.priority != nil {
+				p = o.priority(ctx)
+			}
+			if p >= Critical {
+				h(ctx)
+				return
+			}
+			if o.usage != nil && b.shouldDrop(o.usage() > o.threshold, priorityFactor[p]) {
+				o.onShed(ctx)
+				return
+			}
+			done, err := l.Allow(ctx)
+			if err != nil {
+				o.onShed(ctx)
+				return
+			}
+			start, returned := b.start(), false
+			defer func() {
+				rt := b.finish(start)
+				// NOTE a panicking handler is reported as Ignore, deferred
+				// so it does not leak the in-flight slots.
+				op := rate.Ignore
+				if returned && ctx.Response.StatusCode() < fasthttp.StatusInternalServerError {
+					op = rate.Success
+					if o.dropAfter > 0 && rt > o.dropAfter {
+						op = rate.Drop
+					}
+				}
+				done(op)
+			}()
+			h(ctx)
+			returned = true
+		}
+	}
+}
+
+// bbr estimates the max in-flight requests as the max passed requests per
+// bucket times the min average latency of the buckets in the window.
+type bbr struct {
+	mu       sync.Mutex
+	bucket   time.Duration
+	buckets  []bbrBucket
+	now      func() time.Time
+	inFlight int64
+	dropped  time.Time // last drop, shedding goes on for a window after it
+	window   time.Duration
+}
+
+type bbrBucket struct {
+	at     int64 // bucket index of time
+	passed int64
+	rtSum  time.Duration
+}
+
+func newBBR(window time.Duration, n int, now func() time.Time) *bbr {
+	return &bbr{bucket: window / time.Duration(n), buckets: make([]bbrBucket, n), now: now, window: window}
+}
+
+func (b *bbr) shouldDrop(overloaded bool, factor float64) bool {
+	now := b.now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !overloaded && (b.dropped.IsZero() || now.Sub(b.dropped) > b.window) {
+		return false
+	}
+	max := b.maxInFlight(now) * factor
+	if max <= 0 || float64(atomic.LoadInt64(&b.inFlight)) < max {
+		return false
+	}
+	if overloaded {
+		b.dropped = now
+	}
+	return true
+}
+
+func (b *bbr) maxInFlight(now time.Time) float64 {
+	cur := now.UnixNano() / int64(b.bucket)
+	maxPass, minRT := int64(0), time.Duration(math.MaxInt64)
+	for _, bk := range b.buckets {
+		// the current bucket is incomplete
+		if bk.passed == 0 || bk.at == cur || cur-bk.at >= int64(len(b.buckets)) {
+			continue
+		}
+		if bk.passed > maxPass {
+			maxPass = bk.passed
+		}
+		if rt := bk.rtSum / time.Duration(bk.passed); rt < minRT {
+			minRT = rt
+		}
+	}
+	if maxPass == 0 {
+		return 0
+	}
+	return math.Ceil(float64(maxPass) * float64(minRT) / float64(b.bucket))
+}
+
+func (b *bbr) start() time.Time {
+	atomic.AddInt64(&b.inFlight, 1)
+	return b.now()
+}
+
+func (b *bbr) finish(start time.Time) time.Duration {
+	atomic.AddInt64(&b.inFlight, -1)
+	now := b.now()
+	rt := now.Sub(start)
+	cur := now.UnixNano() / int64(b.bucket)
+	b.mu.Lock()
+	bk := &b.buckets[cur%int64(len(b.buckets))]
+	if bk.at != cur {
+		*bk = bbrBucket{at: cur}
+	}
+	bk.passed++
+	bk.rtSum += rt
+	b.mu.Unlock()
+	return rt
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/rate"
+)
+
+func TestShedReports(t *testing.T) {
+	l := &fakeLimiter{allow: true}
+	status, sleep := fasthttp.StatusOK, time.Duration(0)
+	h := NewShed(l, ShedDropAfter(20*time.Millisecond), ShedPriority(func(ctx *fasthttp.RequestCtx) Priority {
+		if string(ctx.Path()) == "/pay" {
+			return Critical
+		}
+		return Normal
+	}))(func(ctx *fasthttp.RequestCtx) {
+		time.Sleep(sleep)
+		ctx.SetStatusCode(status)
+	})
+	serve := func(path string) int {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.SetRequestURI(path)
+		h(ctx)
+		return ctx.Response.StatusCode()
+	}
+
+	serve("/")
+	status = fasthttp.StatusInternalServerError
+	serve("/")
+	status, sleep = fasthttp.StatusOK, 30*time.Millisecond
+	serve("/")
+	assert.Equal(t, []rate.Op{rate.Success, rate.Ignore, rate.Drop}, l.ops)
+
+	l.allow, sleep = false, 0
+	assert.Equal(t, fasthttp.StatusServiceUnavailable, serve("/"))
+	assert.Equal(t, fasthttp.StatusOK, serve("/pay"))
+	assert.Len(t, l.ops, 3, "critical requests bypass the limiter")
+
+	l.allow = true
+	h = NewShed(l)(func(ctx *fasthttp.RequestCtx) { panic("boom") })
+	assert.Panics(t, func() { h(new(fasthttp.RequestCtx)) })
+	assert.Equal(t, rate.Ignore, l.ops[3], "panicking requests release the limiter")
+}
+
+func TestBBR(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b := newBBR(time.Second, 10, func() time.Time { return now })
+
+	// 10 requests of 50ms per 100ms bucket: 5 in flight at best
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 10; j++ {
+			start := b.start()
+			now = now.Add(50 * time.Millisecond)
+			b.finish(start)
+			now = now.Add(-50 * time.Millisecond)
+		}
+		now = now.Add(100 * time.Millisecond)
+	}
+	assert.Equal(t, float64(5), b.maxInFlight(now))
+
+	for i := 0; i < 5; i++ {
+		b.start()
+	}
+	assert.False(t, b.shouldDrop(false, 1), "cpu is fine")
+	assert.True(t, b.shouldDrop(true, 1))
+	assert.False(t, b.shouldDrop(true, priorityFactor[High]))
+	assert.True(t, b.shouldDrop(false, 1), "keeps shedding for a window after a drop")
+	now = now.Add(1500 * time.Millisecond)
+	assert.False(t, b.shouldDrop(false, 1))
+}
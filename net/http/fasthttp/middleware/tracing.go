@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	tracinglog "github.com/opentracing/opentracing-go/log"
+	"github.com/uber/jaeger-client-go"
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/logs"
+	xfasthttp "github.com/any-lyu/go.library/net/http/fasthttp"
+)
+
+const (
+	spanKey    = "tracing.span"
+	contextKey = "tracing.context"
+)
+
+// headerCarrier reads span contexts from request headers.
+type headerCarrier struct {
+	h *fasthttp.RequestHeader
+}
+
+func (c headerCarrier) ForeachKey(handler func(key, val string) error) (err error) {
+	c.h.VisitAll(func(k, v []byte) {
+		if err == nil {
+			err = handler(string(k), string(v))
+		}
+	})
+	return
+}
+
+// NewTracing new a server tracing middleware. It joins the trace of the
+// request headers, or starts one, with a server span named by method and
+// route template, tagged with the method, url and status. The span is
+// finished with the error of the HandlerFunc or a 5xx status tagged.
+// Handlers get a context.Context with the span by ContextFrom. A nil
+// tracer means opentracing.GlobalTracer at request time.
+func NewTracing(tracer opentracing.Tracer) Middleware {
+	return func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			t := tracer
+			if t == nil {
+				t = opentracing.GlobalTracer()
+			}
+			wire, err := t.Extract(opentracing.HTTPHeaders, headerCarrier{&ctx.Request.Header})
+			if err != nil && err != opentracing.ErrSpanContextNotFound {
+				logs.Warn("middleware: tracing extract error(%v)", err)
+			}
+			span := t.StartSpan("HTTP "+string(ctx.Method()), ext.RPCServerOption(wire))
+			ext.Component.Set(span, "fasthttp")
+			ext.HTTPMethod.Set(span, string(ctx.Method()))
+			ext.HTTPUrl.Set(span, string(ctx.RequestURI()))
+			ctx.SetUserValue(spanKey, span)
+			c, cancel := context.WithCancel(opentracing.ContextWithSpan(context.Background(), span))
+			ctx.SetUserValue(contextKey, c)
+			defer func() {
+				cancel()
+				if route := xfasthttp.RouteTemplate(ctx); route != "" {
+					span.SetOperationName(string(ctx.Method()) + " " + route)
+					span.SetTag("http.route", route)
+				}
+				status := ctx.Response.StatusCode()
+				ext.HTTPStatusCode.Set(span, uint16(status))
+				if err := xfasthttp.HandlerError(ctx); err != nil {
+					ext.Error.Set(span, true)
+					span.LogFields(tracinglog.String("event", "error"), tracinglog.String("message", err.Error()))
+				} else if status >= fasthttp.StatusInternalServerError {
+					ext.Error.Set(span, true)
+				}
+				span.Finish()
+			}()
+			h(ctx)
+		}
+	}
+}
+
+// SpanFrom returns the server span started by NewTracing.
+func SpanFrom(ctx *fasthttp.RequestCtx) (opentracing.Span, bool) {
+	span, ok := ctx.UserValue(spanKey).(opentracing.Span)
+	return span, ok
+}
+
+// ContextFrom returns a context.Context of the request carrying the server
+// span, for tracing calls made by the handler. It is cancelled when the
+// handler returns, as ctx is reused by the next request. Without NewTracing
+// it is context.Background.
+func ContextFrom(ctx *fasthttp.RequestCtx) context.Context {
+	if c, ok := ctx.UserValue(contextKey).(context.Context); ok {
+		return c
+	}
+	return context.Background()
+}
+
+// TraceID returns the jaeger trace id of the server span, "" if none.
+func TraceID(ctx *fasthttp.RequestCtx) string {
+	if span, ok := SpanFrom(ctx); ok {
+		if sc, ok := span.Context().(jaeger.SpanContext); ok {
+			return sc.TraceID().String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/errors"
+	xfasthttp "github.com/any-lyu/go.library/net/http/fasthttp"
+)
+
+func TestTracing(t *testing.T) {
+	tracer := mocktracer.New()
+	parent := tracer.StartSpan("client")
+	var (
+		inner opentracing.Span
+		c     context.Context
+	)
+	r := xfasthttp.NewRouter()
+	r.GET("/users/:id", xfasthttp.HandlerFuncWrapper(func(ctx *fasthttp.RequestCtx) (interface{}, error) {
+		c = ContextFrom(ctx)
+		assert.NoError(t, c.Err())
+		inner = opentracing.SpanFromContext(c)
+		return nil, errors.ErrParams
+	}))
+	h := NewTracing(tracer)(r.Handler)
+
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.SetRequestURI("/users/1")
+	header := http.Header{}
+	assert.NoError(t, tracer.Inject(parent.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header)))
+	for k := range header {
+		ctx.Request.Header.Set(k, header.Get(k))
+	}
+	h(ctx)
+	assert.Equal(t, context.Canceled, c.Err(), "done when the handler returns")
+
+	spans := tracer.FinishedSpans()
+	if assert.Len(t, spans, 1) {
+		span := spans[0]
+		assert.Equal(t, inner, span)
+		assert.Equal(t, "GET /users/:id", span.OperationName)
+		assert.Equal(t, parent.Context().(mocktracer.MockSpanContext).TraceID, span.SpanContext.TraceID)
+		assert.Equal(t, parent.Context().(mocktracer.MockSpanContext).SpanID, span.ParentID)
+		assert.Equal(t, "/users/:id", span.Tag("http.route"))
+		assert.Equal(t, uint16(fasthttp.StatusBadRequest), span.Tag("http.status_code"))
+		assert.Equal(t, true, span.Tag("error"))
+	}
+}
+
+func TestTracingStatus(t *testing.T) {
+	tracer := mocktracer.New()
+	h := NewTracing(tracer)(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(fasthttp.StatusBadGateway)
+	})
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.SetRequestURI("/x")
+	h(ctx)
+	span := tracer.FinishedSpans()[0]
+	assert.Equal(t, "HTTP GET", span.OperationName)
+	assert.Equal(t, 0, span.ParentID)
+	assert.Equal(t, true, span.Tag("error"))
+
+	ctx = new(fasthttp.RequestCtx)
+	assert.Equal(t, context.Background(), ContextFrom(ctx))
+	assert.Equal(t, "", TraceID(ctx))
+}
@@ -0,0 +1,304 @@
+package fasthttp
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/errors"
+	"github.com/any-lyu/go.library/logs"
+	"github.com/any-lyu/go.library/tool"
+)
+
+// Envelope is the body written for the result of a HandlerFunc, Code is 0 on
+// success and the errors code of the error otherwise.
+type Envelope struct {
+	XMLName xml.Name    `json:"-" xml:"response"`
+	Code    int         `json:"code" xml:"code"`
+	Message string      `json:"msg" xml:"msg"`
+	Data    interface{} `json:"data" xml:"data,omitempty"`
+}
+
+// ErrUnsupported is returned by an Encoding unable to encode an envelope,
+// the responder falls back to its default encoding.
+var ErrUnsupported = errors.New("fasthttp: unsupported encoding")
+
+// Encoding encodes envelopes into a content type.
+type Encoding interface {
+	ContentType() string
+	Encode(e *Envelope) ([]byte, error)
+}
+
+// HeaderEncoding is an Encoding writing part of the envelope as response
+// headers, they are set only once the envelope is encoded.
+type HeaderEncoding interface {
+	Encoding
+	SetHeaders(h *fasthttp.ResponseHeader, e *Envelope)
+}
+
+type jsonEncoding struct{}
+
+func (jsonEncoding) ContentType() string { return "application/json" }
+
+func (jsonEncoding) Encode(e *Envelope) ([]byte, error) {
+	return json.Marshal(e)
+}
+
+type xmlEncoding struct{}
+
+func (xmlEncoding) ContentType() string { return "application/xml" }
+
+// Encode returns ErrUnsupported for data xml can not marshal, such as maps.
+func (xmlEncoding) Encode(e *Envelope) ([]byte, error) {
+	b, err := xml.Marshal(e)
+	if err != nil {
+		logs.Debug("fasthttp: xml encode error(%v)", err)
+		return nil, ErrUnsupported
+	}
+	return b, nil
+}
+
+type protobufEncoding struct{}
+
+func (protobufEncoding) ContentType() string { return "application/x-protobuf" }
+
+// Encode writes Data as the body, Data must be nil or a proto.Message.
+func (protobufEncoding) Encode(e *Envelope) ([]byte, error) {
+	if e.Data == nil {
+		return nil, nil
+	}
+	msg, ok := e.Data.(proto.Message)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return proto.Marshal(msg)
+}
+
+// SetHeaders writes the code and message as the X-Code and X-Message
+// headers.
+func (protobufEncoding) SetHeaders(h *fasthttp.ResponseHeader, e *Envelope) {
+	h.Set("X-Code", strconv.Itoa(e.Code))
+	if e.Message != "" {
+		h.Set("X-Message", e.Message)
+	}
+}
+
+// Encodings.
+var (
+	JSON     Encoding = jsonEncoding{}
+	XML      Encoding = xmlEncoding{}
+	Protobuf Encoding = protobufEncoding{}
+)
+
+// Stream is returned as *Stream by a HandlerFunc to stream the body with
+// status 200.
+type Stream struct {
+	ContentType string
+	Write       func(w *bufio.Writer) error
+}
+
+// File is returned as *File by a HandlerFunc to send a file, Range and
+// If-Modified-Since requests are served. Name, if set, is the attachment
+// file name offered to the client.
+type File struct {
+	Path string
+	Name string
+}
+
+var statusMap = struct {
+	sync.RWMutex
+	m map[error]int
+}{m: map[error]int{
+	errors.ErrToken:            fasthttp.StatusUnauthorized,
+	errors.ErrAccountForbidden: fasthttp.StatusForbidden,
+	errors.ErrPermission:       fasthttp.StatusForbidden,
+	errors.ErrParams:           fasthttp.StatusBadRequest,
+	errors.ErrTypeMismatch:     fasthttp.StatusBadRequest,
+	errors.ErrInvalid:          fasthttp.StatusBadRequest,
+	errors.ErrNotFount:         fasthttp.StatusNotFound,
+	errors.ErrRepeat:           fasthttp.StatusConflict,
+	errors.ErrLimitExceed:      fasthttp.StatusTooManyRequests,
+	errors.ErrSystemBusy:       fasthttp.StatusServiceUnavailable,
+}}
+
+// RegisterStatus registers the HTTP statuses of errors, used with
+// errors.Register for new errors. It is safe to call while serving.
+func RegisterStatus(m map[error]int) {
+	statusMap.Lock()
+	defer statusMap.Unlock()
+	for err, status := range m {
+		statusMap.m[err] = status
+	}
+}
+
+// StatusOf returns the HTTP status of the cause of err, 500 for errors not
+// registered.
+func StatusOf(err error) int {
+	statusMap.RLock()
+	status, ok := statusMap.m[errors.Cause(err)]
+	statusMap.RUnlock()
+	if ok {
+		return status
+	}
+	return fasthttp.StatusInternalServerError
+}
+
+// Responder writes the results of HandlerFuncs.
+type Responder struct {
+	// Encodings negotiated by the Accept header, the first is the default
+	// for requests accepting none of them.
+	Encodings []Encoding
+	// Status maps an error to the HTTP status, default StatusOf.
+	Status func(err error) int
+}
+
+// DefaultResponder is the responder of HandlerFuncWrapper.
+var DefaultResponder = NewResponder(JSON, XML, Protobuf)
+
+// NewResponder new a responder, JSON only if no encoding is given.
+func NewResponder(encs ...Encoding) *Responder {
+	if len(encs) == 0 {
+		encs = []Encoding{JSON}
+	}
+	return &Responder{Encodings: encs, Status: StatusOf}
+}
+
+// Wrap returns the handler calling fn and writing its result.
+func (r *Responder) Wrap(fn HandlerFunc) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		data, err := fn(ctx)
+		r.Write(ctx, data, err)
+	}
+}
+
+// Write writes data or err. Stream and File data are written as is, any
+// other data or error as an envelope. FieldErrors are written as the data
+// of an errors.ErrParams envelope.
+func (r *Responder) Write(ctx *fasthttp.RequestCtx, data interface{}, err error) {
+	if err != nil {
+		r.writeError(ctx, err)
+		return
+	}
+	switch d := data.(type) {
+	case *Stream:
+		ctx.SetContentType(d.ContentType)
+		ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+			if err := d.Write(w); err != nil {
+				logs.Error("fasthttp: stream %s error(%v)", ctx.Path(), err)
+			}
+		})
+	case *File:
+		if d.Name != "" {
+			ctx.Response.Header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": d.Name}))
+			if typ := mime.TypeByExtension(filepath.Ext(d.Name)); typ != "" {
+				ctx.SetContentType(typ)
+			}
+		}
+		ctx.SendFile(d.Path)
+	default:
+		logs.Debug("Response:", tool.D2S(data))
+		r.encode(ctx, fasthttp.StatusOK, &Envelope{Data: data})
+	}
+}
+
+func (r *Responder) writeError(ctx *fasthttp.RequestCtx, err error) {
+	logs.Debug("ResponseErr:", err.Error())
+	ctx.SetUserValue(errorKey, err)
+	e := &Envelope{}
+	if fe, ok := fieldErrors(err); ok {
+		e.Message, e.Code = errors.ErrCodeMessage(errors.ErrParams)
+		e.Data = fe
+		r.encode(ctx, fasthttp.StatusBadRequest, e)
+		return
+	}
+	e.Message, e.Code = errors.ErrCodeMessage(errors.Cause(err))
+	status := r.Status(err)
+	// NOTE no WWW-Authenticate for 401, the challenge is the one of the
+	// auth middleware.
+	if status == fasthttp.StatusServiceUnavailable || status == fasthttp.StatusTooManyRequests {
+		ctx.Response.Header.Set("Retry-After", "1")
+	}
+	r.encode(ctx, status, e)
+}
+
+func (r *Responder) encode(ctx *fasthttp.RequestCtx, status int, e *Envelope) {
+	enc := r.negotiate(ctx)
+	b, err := enc.Encode(e)
+	if err == ErrUnsupported && enc != r.Encodings[0] {
+		enc = r.Encodings[0]
+		b, err = enc.Encode(e)
+	}
+	if err != nil {
+		logs.Error("fasthttp: encode %s response error(%v)", enc.ContentType(), err)
+		ctx.Error(fasthttp.StatusMessage(fasthttp.StatusInternalServerError), fasthttp.StatusInternalServerError)
+		return
+	}
+	if he, ok := enc.(HeaderEncoding); ok {
+		he.SetHeaders(&ctx.Response.Header, e)
+	}
+	ctx.Response.Header.Add("Vary", "Accept")
+	ctx.SetStatusCode(status)
+	ctx.SetContentType(enc.ContentType())
+	ctx.SetBody(b)
+}
+
+// negotiate returns the encoding most preferred by the Accept header, each
+// encoding has the q of the most specific media range matching it.
+func (r *Responder) negotiate(ctx *fasthttp.RequestCtx) Encoding {
+	header := string(ctx.Request.Header.Peek("Accept"))
+	if header == "" || len(r.Encodings) == 1 {
+		return r.Encodings[0]
+	}
+	qs := make([]float64, len(r.Encodings))
+	specs := make([]int, len(r.Encodings))
+	for _, part := range strings.Split(header, ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		spec := specificity(typ)
+		for i, enc := range r.Encodings {
+			if spec > specs[i] && mediaMatch(typ, enc.ContentType()) {
+				qs[i], specs[i] = q, spec
+			}
+		}
+	}
+	best := 0
+	for i, q := range qs {
+		if q > qs[best] {
+			best = i
+		}
+	}
+	return r.Encodings[best]
+}
+
+func specificity(typ string) int {
+	switch {
+	case typ == "*/*":
+		return 1
+	case strings.HasSuffix(typ, "/*"):
+		return 2
+	}
+	return 3
+}
+
+func mediaMatch(pattern, typ string) bool {
+	if pattern == "*/*" || pattern == typ {
+		return true
+	}
+	return strings.HasSuffix(pattern, "/*") && strings.HasPrefix(typ, pattern[:len(pattern)-1])
+}
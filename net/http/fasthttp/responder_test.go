@@ -0,0 +1,116 @@
+package fasthttp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/errors"
+)
+
+func respond(accept string, data interface{}, err error) *fasthttp.RequestCtx {
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.SetRequestURI("/")
+	if accept != "" {
+		ctx.Request.Header.Set("Accept", accept)
+	}
+	HandlerFuncWrapper(func(*fasthttp.RequestCtx) (interface{}, error) { return data, err })(ctx)
+	return ctx
+}
+
+func TestResponderEnvelope(t *testing.T) {
+	ctx := respond("", map[string]int{"id": 1}, nil)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()))
+	assert.JSONEq(t, `{"code":0,"msg":"","data":{"id":1}}`, string(ctx.Response.Body()))
+
+	ctx = respond("", nil, errors.Wrap(errors.ErrNotFount, "order 1"))
+	assert.Equal(t, fasthttp.StatusNotFound, ctx.Response.StatusCode())
+	var e Envelope
+	assert.NoError(t, json.Unmarshal(ctx.Response.Body(), &e))
+	assert.Equal(t, errors.ErrCode(errors.ErrNotFount), e.Code)
+	assert.Equal(t, errors.ErrNotFount.Error(), e.Message)
+	assert.Equal(t, errors.ErrNotFount, errors.Cause(HandlerError(ctx)))
+
+	ctx = respond("", nil, errors.New("boom"))
+	assert.Equal(t, fasthttp.StatusInternalServerError, ctx.Response.StatusCode())
+	assert.NoError(t, json.Unmarshal(ctx.Response.Body(), &e))
+	assert.Equal(t, errors.ErrCode(errors.ErrSystem), e.Code)
+
+	ctx = respond("", nil, errors.ErrSystemBusy)
+	assert.Equal(t, fasthttp.StatusServiceUnavailable, ctx.Response.StatusCode())
+	assert.Equal(t, "1", string(ctx.Response.Header.Peek("Retry-After")))
+
+	ctx = respond("", nil, errors.ErrToken)
+	assert.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+	assert.Empty(t, ctx.Response.Header.Peek("WWW-Authenticate"))
+
+	errCustom := errors.New("custom")
+	RegisterStatus(map[error]int{errCustom: fasthttp.StatusTeapot})
+	assert.Equal(t, fasthttp.StatusTeapot, StatusOf(errors.Wrap(errCustom, "x")))
+}
+
+func TestResponderNegotiate(t *testing.T) {
+	type item struct {
+		ID int `json:"id" xml:"id"`
+	}
+	ctx := respond("text/html;q=0.9, application/xml", &item{ID: 1}, nil)
+	assert.Equal(t, "application/xml", string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, "<response><code>0</code><msg></msg><data><id>1</id></data></response>", string(ctx.Response.Body()))
+	assert.Equal(t, "Accept", string(ctx.Response.Header.Peek("Vary")))
+
+	// xml can not marshal maps, falls back to json
+	ctx = respond("application/xml", map[string]interface{}{"id": 1}, nil)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()))
+
+	ctx = respond("application/json;q=0.5, application/*;q=0.8", &item{ID: 1}, nil)
+	assert.Equal(t, "application/xml", string(ctx.Response.Header.ContentType()))
+
+	ctx = respond("text/html", &item{ID: 1}, nil)
+	assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()))
+
+	ctx = respond("application/x-protobuf", &wrappers.StringValue{Value: "hi"}, nil)
+	assert.Equal(t, "application/x-protobuf", string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, "0", string(ctx.Response.Header.Peek("X-Code")))
+	var v wrappers.StringValue
+	assert.NoError(t, proto.Unmarshal(ctx.Response.Body(), &v))
+	assert.Equal(t, "hi", v.Value)
+
+	// not a proto.Message, falls back to json
+	ctx = respond("application/x-protobuf", &item{ID: 1}, nil)
+	assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()))
+	assert.Empty(t, ctx.Response.Header.Peek("X-Code"))
+
+	ctx = respond("application/x-protobuf", nil, errors.ErrPermission)
+	assert.Equal(t, fasthttp.StatusForbidden, ctx.Response.StatusCode())
+	assert.Equal(t, "514", string(ctx.Response.Header.Peek("X-Code")))
+	assert.Empty(t, ctx.Response.Body())
+}
+
+func TestResponderStreamFile(t *testing.T) {
+	ctx := respond("", &Stream{ContentType: "text/csv", Write: func(w *bufio.Writer) error {
+		_, err := w.WriteString("a,b\n")
+		return err
+	}}, nil)
+	assert.Equal(t, "text/csv", string(ctx.Response.Header.ContentType()))
+	assert.True(t, ctx.Response.IsBodyStream())
+
+	dir, err := ioutil.TempDir("", "responder")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "report")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("a,b\n"), 0644))
+	ctx = respond("", &File{Path: path, Name: "report.csv"}, nil)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, `attachment; filename=report.csv`, string(ctx.Response.Header.Peek("Content-Disposition")))
+	assert.Equal(t, "a,b\n", string(ctx.Response.Body()))
+}
@@ -0,0 +1,394 @@
+package fasthttp
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/errors"
+)
+
+const routeKey = "router.route"
+
+// Router routes requests by method and path on a radix tree.
+//
+// Paths are static or have params: ":name" matches one segment and "*name"
+// matches the rest of the path, it must be last. Static segments take
+// precedence over params, params over catch-alls. Matched params are stored
+// as string user values of their names:
+//
+//	r := fasthttp.NewRouter()
+//	r.GET("/orders/:id", HandlerFuncWrapper(getOrder)).Name("order")
+//	api := r.Group("/api", middleware.Recover)
+//	api.POST("/files/*path", upload)
+//	fasthttp.ListenAndServe(":8080", r.Handler)
+type Router struct {
+	RouteGroup
+	// NotFound handles unmatched paths, default 404.
+	NotFound fasthttp.RequestHandler
+	// MethodNotAllowed handles paths matched for other methods, with the
+	// Allow header set, default 405.
+	MethodNotAllowed fasthttp.RequestHandler
+	// RedirectTrailingSlash redirects unmatched paths to the path with or
+	// without the trailing slash if it matches, default true.
+	RedirectTrailingSlash bool
+
+	mu     sync.RWMutex
+	root   *node
+	named  map[string]*Route
+	routes []*Route
+}
+
+// Route a registered route.
+type Route struct {
+	Method string
+	Path   string // the template, e.g. /orders/:id
+
+	name    string
+	router  *Router
+	handler fasthttp.RequestHandler
+}
+
+// Name names the route for Router.URL and returns it.
+func (rt *Route) Name(name string) *Route {
+	rt.router.mu.Lock()
+	defer rt.router.mu.Unlock()
+	if _, ok := rt.router.named[name]; ok {
+		panic("router: duplicate route name " + name)
+	}
+	rt.name = name
+	rt.router.named[name] = rt
+	return rt
+}
+
+// NewRouter new a router.
+func NewRouter() *Router {
+	r := &Router{root: new(node), named: make(map[string]*Route), RedirectTrailingSlash: true}
+	r.RouteGroup.router = r
+	return r
+}
+
+// RouteGroup a set of routes sharing a path prefix and middlewares.
+type RouteGroup struct {
+	router *Router
+	prefix string
+	mws    []func(fasthttp.RequestHandler) fasthttp.RequestHandler
+}
+
+// Group new a sub group of prefix, its routes are wrapped by the group
+// middlewares then mws, the first middleware outermost.
+func (g *RouteGroup) Group(prefix string, mws ...func(fasthttp.RequestHandler) fasthttp.RequestHandler) *RouteGroup {
+	sub := &RouteGroup{router: g.router, prefix: g.prefix + prefix}
+	sub.mws = append(append(sub.mws, g.mws...), mws...)
+	return sub
+}
+
+// Use appends middlewares to the group, they wrap routes registered after.
+func (g *RouteGroup) Use(mws ...func(fasthttp.RequestHandler) fasthttp.RequestHandler) {
+	g.mws = append(g.mws, mws...)
+}
+
+// Handle registers h for method and path, panics on a bad or conflicting
+// path.
+func (g *RouteGroup) Handle(method, path string, h fasthttp.RequestHandler) *Route {
+	for i := len(g.mws) - 1; i >= 0; i-- {
+		h = g.mws[i](h)
+	}
+	return g.router.add(method, g.prefix+path, h)
+}
+
+// GET registers h for GET.
+func (g *RouteGroup) GET(path string, h fasthttp.RequestHandler) *Route {
+	return g.Handle(fasthttp.MethodGet, path, h)
+}
+
+// HEAD registers h for HEAD.
+func (g *RouteGroup) HEAD(path string, h fasthttp.RequestHandler) *Route {
+	return g.Handle(fasthttp.MethodHead, path, h)
+}
+
+// POST registers h for POST.
+func (g *RouteGroup) POST(path string, h fasthttp.RequestHandler) *Route {
+	return g.Handle(fasthttp.MethodPost, path, h)
+}
+
+// PUT registers h for PUT.
+func (g *RouteGroup) PUT(path string, h fasthttp.RequestHandler) *Route {
+	return g.Handle(fasthttp.MethodPut, path, h)
+}
+
+// PATCH registers h for PATCH.
+func (g *RouteGroup) PATCH(path string, h fasthttp.RequestHandler) *Route {
+	return g.Handle(fasthttp.MethodPatch, path, h)
+}
+
+// DELETE registers h for DELETE.
+func (g *RouteGroup) DELETE(path string, h fasthttp.RequestHandler) *Route {
+	return g.Handle(fasthttp.MethodDelete, path, h)
+}
+
+// OPTIONS registers h for OPTIONS.
+func (g *RouteGroup) OPTIONS(path string, h fasthttp.RequestHandler) *Route {
+	return g.Handle(fasthttp.MethodOptions, path, h)
+}
+
+func (r *Router) add(method, path string, h fasthttp.RequestHandler) *Route {
+	if path == "" || path[0] != '/' {
+		panic("router: path must begin with '/' in " + path)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.root.insert(path, path)
+	if n.routes == nil {
+		n.routes = make(map[string]*Route)
+	}
+	if _, ok := n.routes[method]; ok {
+		panic("router: duplicate route " + method + " " + path)
+	}
+	rt := &Route{Method: method, Path: path, router: r, handler: h}
+	n.routes[method] = rt
+	r.routes = append(r.routes, rt)
+	return rt
+}
+
+// Routes returns the registered routes.
+func (r *Router) Routes() []*Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*Route(nil), r.routes...)
+}
+
+// URL builds the path of the named route from name value pairs of params.
+func (r *Router) URL(name string, params ...string) (string, error) {
+	r.mu.RLock()
+	rt, ok := r.named[name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", errors.Errorf("router: no route named %q", name)
+	}
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i+1 < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+	segs := strings.Split(rt.Path, "/")
+	for i, seg := range segs {
+		if seg == "" || (seg[0] != ':' && seg[0] != '*') {
+			continue
+		}
+		v, ok := values[seg[1:]]
+		if !ok {
+			return "", errors.Errorf("router: route %q missing param %q", name, seg[1:])
+		}
+		if seg[0] == '*' {
+			v = strings.TrimPrefix(v, "/")
+		}
+		segs[i] = v
+	}
+	return strings.Join(segs, "/"), nil
+}
+
+// RouteTemplate returns the template of the route matched for ctx, such as
+// /orders/:id, "" if none matched. Use it to label metrics.
+func RouteTemplate(ctx *fasthttp.RequestCtx) string {
+	s, _ := ctx.UserValue(routeKey).(string)
+	return s
+}
+
+// Handler is the fasthttp.RequestHandler of the router.
+func (r *Router) Handler(ctx *fasthttp.RequestCtx) {
+	method, path := string(ctx.Method()), string(ctx.Path())
+	r.mu.RLock()
+	n, params := r.root.lookup(method, path, nil)
+	r.mu.RUnlock()
+	if n != nil {
+		rt := n.routes[method]
+		for _, p := range params {
+			ctx.SetUserValue(p.name, p.value)
+		}
+		ctx.SetUserValue(routeKey, rt.Path)
+		rt.handler(ctx)
+		return
+	}
+	if r.RedirectTrailingSlash && path != "/" {
+		fixed := path + "/"
+		if strings.HasSuffix(path, "/") {
+			fixed = path[:len(path)-1]
+		}
+		r.mu.RLock()
+		n, _ = r.root.lookup(method, fixed, nil)
+		r.mu.RUnlock()
+		if n != nil {
+			code := fasthttp.StatusMovedPermanently
+			if method != fasthttp.MethodGet && method != fasthttp.MethodHead {
+				code = fasthttp.StatusPermanentRedirect
+			}
+			if q := ctx.URI().QueryString(); len(q) > 0 {
+				fixed += "?" + string(q)
+			}
+			ctx.Redirect(fixed, code)
+			return
+		}
+	}
+	r.mu.RLock()
+	n, _ = r.root.lookup("", path, nil)
+	var allow []string
+	if n != nil {
+		for m := range n.routes {
+			allow = append(allow, m)
+		}
+	}
+	r.mu.RUnlock()
+	if len(allow) > 0 {
+		sort.Strings(allow)
+		ctx.Response.Header.Set("Allow", strings.Join(allow, ", "))
+		if r.MethodNotAllowed != nil {
+			r.MethodNotAllowed(ctx)
+			return
+		}
+		ctx.Error(fasthttp.StatusMessage(fasthttp.StatusMethodNotAllowed), fasthttp.StatusMethodNotAllowed)
+		ctx.Response.Header.Set("Allow", strings.Join(allow, ", "))
+		return
+	}
+	if r.NotFound != nil {
+		r.NotFound(ctx)
+		return
+	}
+	ctx.Error(fasthttp.StatusMessage(fasthttp.StatusNotFound), fasthttp.StatusNotFound)
+}
+
+type param struct {
+	name, value string
+}
+
+// node a radix tree node, static children share no first byte.
+type node struct {
+	path      string // static prefix matched by the node
+	static    []*node
+	param     *node
+	paramName string
+	catchAll  *node
+	catchName string
+	routes    map[string]*Route // method to route, routes end at the node
+}
+
+func (n *node) insert(path, full string) *node {
+	for path != "" {
+		switch path[0] {
+		case ':':
+			end := strings.IndexByte(path, '/')
+			if end < 0 {
+				end = len(path)
+			}
+			name := path[1:end]
+			if name == "" {
+				panic("router: empty param name in " + full)
+			}
+			if n.param == nil {
+				n.param, n.paramName = new(node), name
+			} else if n.paramName != name {
+				panic("router: param :" + name + " conflicts with :" + n.paramName + " in " + full)
+			}
+			n, path = n.param, path[end:]
+		case '*':
+			name := path[1:]
+			if name == "" || strings.IndexByte(name, '/') >= 0 {
+				panic("router: catch-all must be a named last segment in " + full)
+			}
+			if n.catchAll == nil {
+				n.catchAll, n.catchName = new(node), name
+			} else if n.catchName != name {
+				panic("router: catch-all *" + name + " conflicts with *" + n.catchName + " in " + full)
+			}
+			return n.catchAll
+		default:
+			end := strings.IndexAny(path, ":*")
+			if end < 0 {
+				end = len(path)
+			}
+			if end < len(path) && path[end-1] != '/' {
+				panic("router: param must begin a segment in " + full)
+			}
+			n, path = n.insertStatic(path[:end]), path[end:]
+		}
+	}
+	return n
+}
+
+// insertStatic walks down the static path s, splitting nodes sharing a
+// prefix with it, and returns the node ending at s.
+func (n *node) insertStatic(s string) *node {
+	for s != "" {
+		var child *node
+		for _, c := range n.static {
+			if c.path[0] == s[0] {
+				child = c
+				break
+			}
+		}
+		if child == nil {
+			child = &node{path: s}
+			n.static = append(n.static, child)
+			return child
+		}
+		l := 0
+		for l < len(s) && l < len(child.path) && s[l] == child.path[l] {
+			l++
+		}
+		if l < len(child.path) {
+			sub := *child
+			sub.path = child.path[l:]
+			*child = node{path: child.path[:l], static: []*node{&sub}}
+		}
+		n, s = child, s[l:]
+	}
+	return n
+}
+
+// lookup finds the node of path having a route for method, or any route
+// if method is empty.
+func (n *node) lookup(method, path string, params []param) (*node, []param) {
+	if path == "" {
+		if n.has(method) {
+			return n, params
+		}
+		if n.catchAll != nil && n.catchAll.has(method) {
+			return n.catchAll, append(params, param{n.catchName, ""})
+		}
+		return nil, nil
+	}
+	for _, c := range n.static {
+		if c.path[0] == path[0] {
+			if strings.HasPrefix(path, c.path) {
+				if m, ps := c.lookup(method, path[len(c.path):], params); m != nil {
+					return m, ps
+				}
+			}
+			break
+		}
+	}
+	if n.param != nil {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			if m, ps := n.param.lookup(method, path[end:], append(params, param{n.paramName, path[:end]})); m != nil {
+				return m, ps
+			}
+		}
+	}
+	if n.catchAll != nil && n.catchAll.has(method) {
+		return n.catchAll, append(params, param{n.catchName, path})
+	}
+	return nil, nil
+}
+
+func (n *node) has(method string) bool {
+	if method == "" {
+		return len(n.routes) > 0
+	}
+	_, ok := n.routes[method]
+	return ok
+}
@@ -0,0 +1,124 @@
+package fasthttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func echo(name string) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.WriteString(name)
+		ctx.VisitUserValues(func(k []byte, v interface{}) {
+			if string(k) != routeKey {
+				ctx.WriteString(" " + string(k) + "=" + v.(string))
+			}
+		})
+	}
+}
+
+func serve(r *Router, method, uri string) *fasthttp.RequestCtx {
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI(uri)
+	r.Handler(ctx)
+	return ctx
+}
+
+func TestRouter(t *testing.T) {
+	r := NewRouter()
+	r.GET("/", echo("root"))
+	r.GET("/orders", echo("list"))
+	r.POST("/orders", echo("create"))
+	r.GET("/orders/new", echo("new"))
+	r.GET("/orders/:id", echo("get"))
+	r.DELETE("/orders/:id", echo("delete"))
+	r.GET("/orders/:id/items/:item", echo("item"))
+	r.GET("/organizations/:org", echo("org"))
+	r.GET("/static/*path", echo("static"))
+	r.GET("/static/favicon.ico", echo("favicon"))
+
+	tests := []struct {
+		method, uri, body, route string
+	}{
+		{"GET", "/", "root", "/"},
+		{"GET", "/orders", "list", "/orders"},
+		{"POST", "/orders", "create", "/orders"},
+		{"GET", "/orders/new", "new", "/orders/new"},
+		{"GET", "/orders/7", "get id=7", "/orders/:id"},
+		{"DELETE", "/orders/new", "delete id=new", "/orders/:id"},
+		{"GET", "/orders/7/items/9", "item id=7 item=9", "/orders/:id/items/:item"},
+		{"GET", "/organizations/acme", "org org=acme", "/organizations/:org"},
+		{"GET", "/static/css/a.css", "static path=css/a.css", "/static/*path"},
+		{"GET", "/static/", "static path=", "/static/*path"},
+		{"GET", "/static/favicon.ico", "favicon", "/static/favicon.ico"},
+	}
+	for _, tt := range tests {
+		ctx := serve(r, tt.method, tt.uri)
+		assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode(), tt.uri)
+		assert.Equal(t, tt.body, string(ctx.Response.Body()), tt.uri)
+		assert.Equal(t, tt.route, RouteTemplate(ctx), tt.uri)
+	}
+
+	ctx := serve(r, "PUT", "/orders/7")
+	assert.Equal(t, fasthttp.StatusMethodNotAllowed, ctx.Response.StatusCode())
+	assert.Equal(t, "DELETE, GET", string(ctx.Response.Header.Peek("Allow")))
+
+	ctx = serve(r, "GET", "/missing")
+	assert.Equal(t, fasthttp.StatusNotFound, ctx.Response.StatusCode())
+	assert.Equal(t, "", RouteTemplate(ctx))
+
+	ctx = serve(r, "GET", "/orders/?page=2")
+	assert.Equal(t, fasthttp.StatusMovedPermanently, ctx.Response.StatusCode())
+	assert.Contains(t, string(ctx.Response.Header.Peek("Location")), "/orders?page=2")
+	ctx = serve(r, "POST", "/orders/")
+	assert.Equal(t, fasthttp.StatusPermanentRedirect, ctx.Response.StatusCode())
+	ctx = serve(r, "PUT", "/orders/")
+	assert.Equal(t, fasthttp.StatusNotFound, ctx.Response.StatusCode())
+}
+
+func TestRouterConflicts(t *testing.T) {
+	r := NewRouter()
+	r.GET("/orders/:id", echo("get"))
+	assert.Panics(t, func() { r.GET("/orders/:id", echo("dup")) })
+	assert.Panics(t, func() { r.GET("/orders/:no", echo("conflict")) })
+	assert.Panics(t, func() { r.GET("/files/*", echo("unnamed")) })
+	assert.Panics(t, func() { r.GET("/files/*path/x", echo("not last")) })
+	assert.Panics(t, func() { r.GET("/files/a:b", echo("mid segment")) })
+	assert.Panics(t, func() { r.GET("orders", echo("relative")) })
+}
+
+func TestRouterGroups(t *testing.T) {
+	var calls []string
+	mw := func(name string) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+			return func(ctx *fasthttp.RequestCtx) {
+				calls = append(calls, name)
+				h(ctx)
+			}
+		}
+	}
+	r := NewRouter()
+	r.Use(mw("root"))
+	api := r.Group("/api", mw("api"))
+	v1 := api.Group("/v1", mw("v1"))
+	v1.GET("/orders/:id", echo("get")).Name("order")
+	api.Use(mw("late"))
+	api.GET("/ping", echo("ping"))
+
+	serve(r, "GET", "/api/v1/orders/7")
+	assert.Equal(t, []string{"root", "api", "v1"}, calls)
+	calls = nil
+	serve(r, "GET", "/api/ping")
+	assert.Equal(t, []string{"root", "api", "late"}, calls)
+
+	u, err := r.URL("order", "id", "7")
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/v1/orders/7", u)
+	_, err = r.URL("order")
+	assert.Error(t, err)
+	_, err = r.URL("missing")
+	assert.Error(t, err)
+	assert.Len(t, r.Routes(), 2)
+}
@@ -0,0 +1,269 @@
+package fasthttp
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/app"
+	"github.com/any-lyu/go.library/errors"
+	"github.com/any-lyu/go.library/logs"
+	"github.com/any-lyu/go.library/net/netutil"
+	xtime "github.com/any-lyu/go.library/time"
+)
+
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+)
+
+// ServerConfig server config.
+type ServerConfig struct {
+	Name               string         `json:"name"`
+	Addr               string         `json:"addr"`
+	MaxConns           int32          `json:"max_conns"` // 0 means no limit
+	ReadTimeout        xtime.Duration `json:"read_timeout"`
+	WriteTimeout       xtime.Duration `json:"write_timeout"`
+	IdleTimeout        xtime.Duration `json:"idle_timeout"`
+	MaxRequestBodySize int            `json:"max_request_body_size"`
+	// DrainDelay is how long /readyz reports not ready before the listener
+	// is closed at shutdown, for load balancers to stop sending requests.
+	DrainDelay xtime.Duration `json:"drain_delay"`
+	// ShutdownTimeout is how long in-flight requests are waited for after
+	// the listener is closed, default 10s.
+	ShutdownTimeout xtime.Duration `json:"shutdown_timeout"`
+}
+
+// Server is a fasthttp server shut down gracefully with app. It serves
+// /healthz, always 200 while serving, and /readyz, 503 once the shutdown
+// starts.
+type Server struct {
+	conf    *ServerConfig
+	srv     *fasthttp.Server
+	ready   int32
+	mu      sync.Mutex
+	ln      net.Listener
+	serveCh chan error
+	stop    sync.Once
+	stopErr error
+	conns   map[*trackConn]struct{}
+}
+
+// trackListener tracks the accepted connections, so idle keep-alive
+// connections can be closed at shutdown.
+type trackListener struct {
+	net.Listener
+	s *Server
+}
+
+func (l *trackListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tc := &trackConn{Conn: c, s: l.s}
+	l.s.mu.Lock()
+	l.s.conns[tc] = struct{}{}
+	l.s.mu.Unlock()
+	return tc, nil
+}
+
+const (
+	connIdle    = iota // waiting for a request
+	connBusy           // reading or serving a request
+	connClosing        // interrupted by shutdown
+)
+
+type trackConn struct {
+	net.Conn
+	s     *Server
+	mu    sync.Mutex
+	state int
+	once  sync.Once
+}
+
+// Read marks the connection busy once request bytes arrive, so a request
+// being read is not interrupted by shutdown.
+func (c *trackConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.mu.Lock()
+		if c.state == connClosing {
+			// NOTE the request arrived as the connection was interrupted,
+			// let it complete.
+			var deadline time.Time
+			if d := c.s.srv.ReadTimeout; d > 0 {
+				deadline = time.Now().Add(d)
+			}
+			c.Conn.SetReadDeadline(deadline)
+		}
+		c.state = connBusy
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+func (c *trackConn) setState(state int) {
+	c.mu.Lock()
+	c.state = state
+	c.mu.Unlock()
+}
+
+// interrupt makes an idle connection stop waiting for its next request.
+func (c *trackConn) interrupt(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == connIdle {
+		c.state = connClosing
+		c.Conn.SetReadDeadline(now)
+	}
+}
+
+func (c *trackConn) Close() error {
+	c.once.Do(func() {
+		c.s.mu.Lock()
+		delete(c.s.conns, c)
+		c.s.mu.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+// NewServer new a server of h.
+func NewServer(c *ServerConfig, h fasthttp.RequestHandler) *Server {
+	if c.ShutdownTimeout <= 0 {
+		c.ShutdownTimeout = xtime.Duration(10 * time.Second)
+	}
+	s := &Server{conf: c, serveCh: make(chan error, 1), conns: make(map[*trackConn]struct{})}
+	s.srv = &fasthttp.Server{
+		Name:               c.Name,
+		Handler:            s.handler(h),
+		ReadTimeout:        time.Duration(c.ReadTimeout),
+		WriteTimeout:       time.Duration(c.WriteTimeout),
+		IdleTimeout:        time.Duration(c.IdleTimeout),
+		MaxRequestBodySize: c.MaxRequestBodySize,
+	}
+	return s
+}
+
+func (s *Server) handler(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if c, ok := ctx.Conn().(*trackConn); ok {
+			c.setState(connBusy)
+			defer c.setState(connIdle)
+		}
+		ready := s.Ready()
+		if !ready {
+			// NOTE let clients reconnect to a serving instance.
+			ctx.SetConnectionClose()
+		}
+		switch string(ctx.Path()) {
+		case healthzPath:
+			ctx.SetBodyString("ok")
+		case readyzPath:
+			if !ready {
+				ctx.Error("shutting down", fasthttp.StatusServiceUnavailable)
+				return
+			}
+			ctx.SetBodyString("ok")
+		default:
+			h(ctx)
+		}
+	}
+}
+
+// Ready reports whether the server is serving and not shutting down.
+func (s *Server) Ready() bool {
+	return atomic.LoadInt32(&s.ready) == 1
+}
+
+// Addr returns the listening address, nil before serving.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Addr()
+}
+
+// Start listens on the tcp address of config and serves in an app.Go
+// goroutine, which shuts the server down on app.Closing, so app.Wait
+// returns after the in-flight requests are done.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.conf.Addr)
+	if err != nil {
+		return errors.Wrapf(err, "fasthttp: listen %s", s.conf.Addr)
+	}
+	app.Go(context.Background(), func(_ context.Context, closing <-chan struct{}) {
+		go s.Serve(ln)
+		select {
+		case <-closing:
+			if err := s.Shutdown(); err != nil {
+				logs.Error("fasthttp: server %s shutdown error(%v)", s.conf.Addr, err)
+			}
+		case err := <-s.serveCh:
+			logs.Error("fasthttp: server %s serve error(%v)", s.conf.Addr, err)
+		}
+	})
+	return nil
+}
+
+// Serve serves ln, limited to MaxConns connections, until Shutdown.
+func (s *Server) Serve(ln net.Listener) error {
+	if s.conf.MaxConns > 0 {
+		ln = netutil.LimitListener(ln, s.conf.MaxConns)
+	}
+	ln = &trackListener{Listener: ln, s: s}
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+	atomic.StoreInt32(&s.ready, 1)
+	err := s.srv.Serve(ln)
+	if err != nil {
+		atomic.StoreInt32(&s.ready, 0)
+		s.serveCh <- err
+	}
+	return err
+}
+
+// Shutdown flips /readyz to not ready, waits DrainDelay, then stops
+// accepting and waits up to ShutdownTimeout for in-flight requests. Idle
+// keep-alive connections are closed, the ones reading or serving a request
+// are closed after their response.
+func (s *Server) Shutdown() error {
+	s.stop.Do(func() {
+		atomic.StoreInt32(&s.ready, 0)
+		time.Sleep(time.Duration(s.conf.DrainDelay))
+		done := make(chan error, 1)
+		go func() { done <- s.srv.Shutdown() }()
+		timer := time.NewTimer(time.Duration(s.conf.ShutdownTimeout))
+		defer timer.Stop()
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for s.closeIdle(); ; s.closeIdle() {
+			select {
+			case s.stopErr = <-done:
+				return
+			case <-timer.C:
+				s.stopErr = errors.Errorf("fasthttp: shutdown timeout %v, %d connections open", time.Duration(s.conf.ShutdownTimeout), s.srv.GetOpenConnectionsCount())
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+	return s.stopErr
+}
+
+// closeIdle interrupts the connections waiting for their next request.
+func (s *Server) closeIdle() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.interrupt(now)
+	}
+}
@@ -0,0 +1,120 @@
+package fasthttp
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/any-lyu/go.library/app"
+	xtime "github.com/any-lyu/go.library/time"
+)
+
+func listen(t *testing.T, s *Server) (*fasthttp.HostClient, net.Listener) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	time.Sleep(10 * time.Millisecond)
+	return &fasthttp.HostClient{Addr: ln.Addr().String()}, ln
+}
+
+func get(c *fasthttp.HostClient, uri string) (int, error) {
+	code, _, err := c.Get(nil, "http://"+c.Addr+uri)
+	return code, err
+}
+
+func TestServerShutdown(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	s := NewServer(&ServerConfig{DrainDelay: xtime.Duration(100 * time.Millisecond)}, func(ctx *fasthttp.RequestCtx) {
+		close(entered)
+		<-release
+		ctx.SetBodyString("done")
+	})
+	c, ln := listen(t, s)
+
+	code, err := get(c, "/readyz")
+	assert.NoError(t, err)
+	assert.Equal(t, fasthttp.StatusOK, code)
+
+	inflight := make(chan int)
+	go func() {
+		code, _ := get(c, "/slow")
+		inflight <- code
+	}()
+	<-entered
+	stopped := make(chan error)
+	go func() { stopped <- s.Shutdown() }()
+	time.Sleep(20 * time.Millisecond)
+	assert.False(t, s.Ready())
+	code, err = get(c, "/readyz")
+	assert.NoError(t, err)
+	assert.Equal(t, fasthttp.StatusServiceUnavailable, code)
+	code, err = get(c, "/healthz")
+	assert.NoError(t, err)
+	assert.Equal(t, fasthttp.StatusOK, code)
+
+	close(release)
+	assert.Equal(t, fasthttp.StatusOK, <-inflight)
+	assert.NoError(t, <-stopped)
+	_, err = net.Dial("tcp", ln.Addr().String())
+	assert.Error(t, err)
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	s := NewServer(&ServerConfig{ShutdownTimeout: xtime.Duration(50 * time.Millisecond)}, func(ctx *fasthttp.RequestCtx) {
+		close(entered)
+		<-release
+	})
+	c, _ := listen(t, s)
+	go get(c, "/slow")
+	<-entered
+	assert.Error(t, s.Shutdown())
+}
+
+func TestServerStart(t *testing.T) {
+	s := NewServer(&ServerConfig{Addr: "127.0.0.1:0", MaxConns: 4}, func(ctx *fasthttp.RequestCtx) {})
+	assert.NoError(t, s.Start())
+	time.Sleep(10 * time.Millisecond)
+	addr := s.Addr().String()
+	code, _, err := fasthttp.Get(nil, "http://"+addr+"/readyz")
+	assert.NoError(t, err)
+	assert.Equal(t, fasthttp.StatusOK, code)
+
+	app.Close()
+	app.Wait()
+	assert.False(t, s.Ready())
+	_, err = net.Dial("tcp", addr)
+	assert.Error(t, err)
+}
+
+func TestServerShutdownPartialRequest(t *testing.T) {
+	s := NewServer(&ServerConfig{}, func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString("done")
+	})
+	_, ln := listen(t, s)
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /x HTTP/1.1\r\nHost: test\r\n"))
+	assert.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	stopped := make(chan error)
+	go func() { stopped <- s.Shutdown() }()
+	// let shutdown interrupt the idle connections
+	time.Sleep(100 * time.Millisecond)
+	_, err = conn.Write([]byte("\r\n"))
+	assert.NoError(t, err)
+	var resp fasthttp.Response
+	assert.NoError(t, resp.Read(bufio.NewReader(conn)))
+	assert.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+	assert.Equal(t, "done", string(resp.Body()))
+	assert.NoError(t, <-stopped)
+}
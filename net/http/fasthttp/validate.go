@@ -0,0 +1,184 @@
+package fasthttp
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/any-lyu/go.library/errors"
+)
+
+// Validate checks the validate tags of the struct pointed by v, returning
+// FieldErrors for the failed fields. Rules are comma separated:
+//
+//	required      not the zero value
+//	min=n, max=n  bounds of numbers, of the length of strings in characters
+//	              and of slices and maps
+//	enum=a|b|c    one of the values
+//	regex=expr    strings matching expr, it must be the last rule as expr
+//	              may contain commas
+//
+// Nil pointer fields are only checked by required, use them for optional
+// fields. Struct, pointer to struct and slice of struct fields are validated
+// recursively.
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return errors.Errorf("validate: %T is not a struct", v)
+	}
+	info, err := structInfoOf(rv.Type())
+	if err != nil {
+		return err
+	}
+	if errs := info.validate(rv, "", nil); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+type rule struct {
+	name  string
+	arg   string
+	num   float64
+	enum  []string
+	regex *regexp.Regexp
+}
+
+func parseRules(tag string) (rules []*rule, err error) {
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "regex=") {
+			part, tag = tag, ""
+		} else if i := strings.IndexByte(tag, ','); i >= 0 {
+			part, tag = tag[:i], tag[i+1:]
+		} else {
+			part, tag = tag, ""
+		}
+		r := &rule{name: part}
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			r.name, r.arg = part[:i], part[i+1:]
+		}
+		switch r.name {
+		case "required":
+		case "min", "max":
+			if r.num, err = strconv.ParseFloat(r.arg, 64); err != nil {
+				return nil, errors.Errorf("bad rule %q", part)
+			}
+		case "enum":
+			r.enum = strings.Split(r.arg, "|")
+		case "regex":
+			if r.regex, err = regexp.Compile(r.arg); err != nil {
+				return nil, errors.Wrapf(err, "bad rule %q", part)
+			}
+		default:
+			return nil, errors.Errorf("unknown rule %q", part)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func (info *structInfo) validate(v reflect.Value, prefix string, errs FieldErrors) FieldErrors {
+	for _, f := range info.fields {
+		fv := v.FieldByIndex(f.index)
+		name := prefix + f.name
+		zero := isZero(fv)
+		for _, r := range f.rules {
+			if r.name != "required" && zero && (fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface) {
+				continue
+			}
+			if msg := r.check(fv, zero); msg != "" {
+				errs = append(errs, &FieldError{Field: name, Rule: r.name, Message: msg})
+				if r.name == "required" {
+					break
+				}
+			}
+		}
+		if f.sub == nil || (zero && fv.Kind() != reflect.Struct) {
+			continue
+		}
+		switch fv.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < fv.Len(); i++ {
+				if ev := indirect(fv.Index(i)); ev.IsValid() {
+					errs = f.sub.validate(ev, fmt.Sprintf("%s[%d].", name, i), errs)
+				}
+			}
+		default:
+			if ev := indirect(fv); ev.IsValid() {
+				errs = f.sub.validate(ev, name+".", errs)
+			}
+		}
+	}
+	return errs
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		return v.Elem()
+	}
+	return v
+}
+
+func (r *rule) check(v reflect.Value, zero bool) string {
+	switch r.name {
+	case "required":
+		if zero {
+			return "is required"
+		}
+	case "min", "max":
+		n, unit := measure(indirect(v))
+		if r.name == "min" && n < r.num {
+			return "must be at least " + r.arg + unit
+		}
+		if r.name == "max" && n > r.num {
+			return "must be at most " + r.arg + unit
+		}
+	case "enum":
+		s := fmt.Sprint(indirect(v).Interface())
+		for _, e := range r.enum {
+			if s == e {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(r.enum, ", ")
+	case "regex":
+		if v := indirect(v); v.Kind() == reflect.String && !r.regex.MatchString(v.String()) {
+			return "must match " + r.arg
+		}
+	}
+	return ""
+}
+
+func measure(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " items"
+	}
+	return 0, ""
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return v.IsNil() || (v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface && v.Len() == 0)
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
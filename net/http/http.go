@@ -0,0 +1,245 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
+	"github.com/any-lyu/go.library/errors"
+	"github.com/any-lyu/go.library/net/netutil"
+	"github.com/any-lyu/go.library/net/netutil/breaker"
+	"github.com/any-lyu/go.library/stat"
+	xtime "github.com/any-lyu/go.library/time"
+	"github.com/any-lyu/go.library/tracing"
+)
+
+// Config client config.
+type Config struct {
+	// Timeout of each attempt, reading the body included, default 5s.
+	Timeout             xtime.Duration `json:"timeout"`
+	Dial                xtime.Duration `json:"dial"`       // default 1s
+	KeepAlive           xtime.Duration `json:"keep_alive"` // default 60s
+	MaxIdleConnsPerHost int            `json:"max_idle_conns_per_host"`
+	// Headers are set on requests not setting them.
+	Headers map[string]string `json:"headers"`
+	// Retries is the max retries after the first attempt, 0 means none.
+	Retries int `json:"retries"`
+	// Backoff between retries, default 100ms growing to 2s.
+	Backoff *netutil.BackoffConfig `json:"backoff"`
+	// Breaker of each host, default the breaker package config.
+	Breaker *breaker.Config `json:"breaker"`
+	// Stat receives latency and code of requests by url, default
+	// stat.HTTPClient.
+	Stat stat.Stat `json:"-"`
+	// Transport sends requests, such as a Replayer in tests, default an
+	// http.Transport of Dial, KeepAlive and MaxIdleConnsPerHost.
+	Transport http.RoundTripper `json:"-"`
+	// Middlewares wrap Transport, the first is the outermost.
+	Middlewares []Middleware `json:"-"`
+}
+
+func (c *Config) fix() {
+	if c.Timeout <= 0 {
+		c.Timeout = xtime.Duration(5 * time.Second)
+	}
+	if c.Dial <= 0 {
+		c.Dial = xtime.Duration(time.Second)
+	}
+	if c.KeepAlive <= 0 {
+		c.KeepAlive = xtime.Duration(60 * time.Second)
+	}
+	if c.Backoff == nil {
+		c.Backoff = &netutil.BackoffConfig{BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second, Factor: 1.6, Jitter: 0.2}
+	}
+	if c.Stat == nil {
+		c.Stat = stat.HTTPClient
+	}
+}
+
+// Client is an http client with retries, a breaker per host, tracing and
+// metrics.
+type Client struct {
+	conf     *Config
+	client   *http.Client
+	breakers *breaker.Group
+}
+
+// NewClient new a client of c.
+func NewClient(c *Config) *Client {
+	conf := *c
+	conf.fix()
+	transport := conf.Transport
+	if transport == nil {
+		dialer := &net.Dialer{Timeout: time.Duration(conf.Dial), KeepAlive: time.Duration(conf.KeepAlive)}
+		transport = &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         dialer.DialContext,
+			MaxIdleConnsPerHost: conf.MaxIdleConnsPerHost,
+			IdleConnTimeout:     90 * time.Second,
+		}
+	}
+	mws := append(append([]Middleware(nil), conf.Middlewares...), NewMetrics(conf.Stat))
+	transport = Chain(mws...).Then(transport)
+	return &Client{
+		conf:     &conf,
+		client:   &http.Client{Transport: transport, Timeout: time.Duration(conf.Timeout)},
+		breakers: breaker.NewGroup(conf.Breaker),
+	}
+}
+
+// Do sends req with ctx and returns the response of the last attempt, of
+// any status. Failed attempts of idempotent requests, GET, HEAD, OPTIONS,
+// TRACE, PUT, DELETE or any with an Idempotency-Key header, are retried on
+// network errors and 429, 502, 503 and 504, after the backoff or the
+// Retry-After of the response. Requests with a body are retried only if it
+// can be rewound, as the ones made by http.NewRequest from bytes. Requests
+// to a host whose breaker is open fail with errors.ErrSystemBusy.
+func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	req = req.WithContext(ctx)
+	req.Header = req.Header.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	for k, v := range c.conf.Headers {
+		if req.Header.Get(k) == "" {
+			req.Header.Set(k, v)
+		}
+	}
+	retries := 0
+	if replayable(req) {
+		retries = c.conf.Retries
+	}
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		resp, err := c.attempt(ctx, req)
+		if attempt >= retries || !retryable(ctx, resp, err) {
+			return resp, err
+		}
+		delay := c.conf.Backoff.Backoff(attempt)
+		if resp != nil {
+			if after := retryAfter(resp); after > c.conf.Backoff.MaxDelay {
+				return resp, err
+			} else if after > delay {
+				delay = after
+			}
+			// NOTE drain for the connection to be reused.
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4<<10))
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, req *http.Request) (resp *http.Response, err error) {
+	brk := c.breakers.Get(req.URL.Host)
+	if err = brk.Allow(); err != nil {
+		return nil, errors.Wrapf(err, "http: breaker of %s open", req.URL.Host)
+	}
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		span := opentracing.GlobalTracer().StartSpan("HTTP Client "+req.Method, opentracing.ChildOf(parent.Context()), ext.SpanKindRPCClient)
+		tracing.ContextToHTTP(opentracing.ContextWithSpan(ctx, span), req)
+		defer func() {
+			if resp != nil {
+				ext.HTTPStatusCode.Set(span, uint16(resp.StatusCode))
+			}
+			if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+				ext.Error.Set(span, true)
+			}
+			span.Finish()
+		}()
+	}
+	if resp, err = c.client.Do(req); err != nil {
+		brk.MarkFailed()
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		brk.MarkFailed()
+	} else {
+		brk.MarkSuccess()
+	}
+	return resp, nil
+}
+
+func replayable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil && errors.Cause(err) != errors.ErrSystemBusy
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the delay of the Retry-After header in seconds, 0 if
+// none.
+func retryAfter(resp *http.Response) time.Duration {
+	s, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || s < 0 {
+		return 0
+	}
+	return time.Duration(s) * time.Second
+}
+
+// Get gets uri.
+func (c *Client) Get(ctx context.Context, uri string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.read(ctx, req)
+}
+
+// PostJSON posts obj as json to uri.
+func (c *Client) PostJSON(ctx context.Context, uri string, obj interface{}) ([]byte, error) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, uri, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json;charset=utf-8")
+	return c.read(ctx, req)
+}
+
+func (c *Client) read(ctx context.Context, req *http.Request) ([]byte, error) {
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return readBody(resp)
+}
@@ -0,0 +1,208 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/any-lyu/go.library/errors"
+	"github.com/any-lyu/go.library/net/netutil"
+	"github.com/any-lyu/go.library/net/netutil/breaker"
+)
+
+type fakeStat struct {
+	codes []string
+}
+
+func (s *fakeStat) Timing(name string, time int64, extra ...string) {}
+
+func (s *fakeStat) Incr(name string, extra ...string) {
+	s.codes = append(s.codes, strings.Join(extra, " "))
+}
+
+func (s *fakeStat) State(name string, val int64, extra ...string) {}
+
+func newTestClient(st *fakeStat, retries int) *Client {
+	return NewClient(&Config{
+		Retries: retries,
+		Backoff: &netutil.BackoffConfig{BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, Factor: 2},
+		Headers: map[string]string{"User-Agent": "test"},
+		Stat:    st,
+	})
+}
+
+// flaky fails the first n requests with status.
+func flaky(n int32, status int, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(calls, 1) <= n {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(r.Header.Get("User-Agent") + ":" + string(body)))
+	}))
+}
+
+func TestClientRetry(t *testing.T) {
+	var calls int32
+	srv := flaky(2, http.StatusServiceUnavailable, &calls)
+	defer srv.Close()
+	st := new(fakeStat)
+	c := newTestClient(st, 3)
+
+	b, err := c.Get(context.Background(), srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "test:", string(b))
+	assert.Equal(t, int32(3), calls)
+	assert.Equal(t, []string{"503", "503", "200"}, st.codes)
+
+	// the body is rewound
+	calls = 0
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("v"))
+	resp, err := c.Do(context.Background(), req)
+	assert.NoError(t, err)
+	b, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "test:v", string(b))
+	assert.Equal(t, int32(3), calls)
+
+	// out of retries, the last response is returned
+	calls = 0
+	req, _ = http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("v"))
+	resp, err = newTestClient(st, 1).Do(context.Background(), req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestClientRetryPost(t *testing.T) {
+	var calls int32
+	srv := flaky(1, http.StatusBadGateway, &calls)
+	defer srv.Close()
+	c := newTestClient(new(fakeStat), 3)
+
+	_, err := c.PostJSON(context.Background(), srv.URL, map[string]int{"a": 1})
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), calls, "POST is not retried")
+
+	calls = 0
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("pay"))
+	req.Header.Set("Idempotency-Key", "k1")
+	resp, err := c.Do(context.Background(), req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), calls, "POST with an Idempotency-Key is")
+}
+
+func TestClientRetryAfter(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	resp, err := newTestClient(new(fakeStat), 3).Do(context.Background(), mustRequest(srv.URL))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, int32(1), calls, "Retry-After longer than the max backoff is not waited")
+}
+
+func TestClientContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	c := NewClient(&Config{Retries: 10, Backoff: &netutil.BackoffConfig{BaseDelay: time.Hour, MaxDelay: time.Hour}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.Do(ctx, mustRequest(srv.URL))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestClientBreaker(t *testing.T) {
+	var calls int32
+	srv := flaky(1<<30, http.StatusInternalServerError, &calls)
+	defer srv.Close()
+	c := NewClient(&Config{Breaker: &breaker.Config{Request: 5}, Stat: new(fakeStat)})
+
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		var resp *http.Response
+		if resp, err = c.Do(context.Background(), mustRequest(srv.URL)); err == nil {
+			resp.Body.Close()
+		}
+	}
+	assert.Equal(t, errors.ErrSystemBusy, errors.Cause(err))
+}
+
+func TestClientTracing(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	var traced string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traced = r.Header.Get("Mockpfx-Ids-Traceid")
+	}))
+	defer srv.Close()
+
+	parent := tracer.StartSpan("parent")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+	_, err := newTestClient(new(fakeStat), 0).Get(ctx, srv.URL)
+	assert.NoError(t, err)
+	parent.Finish()
+
+	spans := tracer.FinishedSpans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "HTTP Client GET", spans[0].OperationName)
+	assert.Equal(t, parent.Context().(mocktracer.MockSpanContext).SpanID, spans[0].ParentID)
+	assert.Equal(t, uint16(200), spans[0].Tag("http.status_code"))
+	assert.NotEmpty(t, traced)
+
+	// no span, no headers
+	traced = "none"
+	_, err = newTestClient(new(fakeStat), 0).Get(context.Background(), srv.URL)
+	assert.NoError(t, err)
+	assert.Empty(t, traced)
+}
+
+func TestClientNilHeader(t *testing.T) {
+	var calls int32
+	srv := flaky(0, http.StatusOK, &calls)
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	req := &http.Request{Method: http.MethodGet, URL: u}
+
+	resp, err := newTestClient(new(fakeStat), 0).Do(context.Background(), req)
+	assert.NoError(t, err)
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "test:", string(b), "default headers are set")
+	assert.Nil(t, req.Header)
+}
+
+func mustRequest(uri string) *http.Request {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		panic(err)
+	}
+	return req
+}
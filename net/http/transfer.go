@@ -0,0 +1,243 @@
+package http
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/any-lyu/go.library/errors"
+)
+
+// Progress is called with the bytes transferred so far and the total, -1
+// if unknown.
+type Progress func(done, total int64)
+
+// Part is a part of a multipart form streamed by Upload.
+type Part struct {
+	FieldName string
+	// FileName makes the part a file, "" for a plain field.
+	FileName string
+	// ContentType of a file, default application/octet-stream.
+	ContentType string
+	Reader      io.Reader
+	// Size of Reader for the progress total, -1 if unknown.
+	Size int64
+}
+
+// progressReader counts the bytes read through it.
+type progressReader struct {
+	r        io.Reader
+	done     int64
+	total    int64
+	progress Progress
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.done += int64(n)
+		if r.progress != nil {
+			r.progress(r.done, r.total)
+		}
+	}
+	return n, err
+}
+
+// multipartBody streams parts as a multipart form through a pipe, the
+// reader fails with the error of a part.
+func multipartBody(parts []Part, progress Progress) (io.ReadCloser, string) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	total := int64(0)
+	for _, p := range parts {
+		if p.Size < 0 {
+			total = -1
+			break
+		}
+		total += p.Size
+	}
+	go func() {
+		counted := &progressReader{total: total, progress: progress}
+		for _, p := range parts {
+			h := make(textproto.MIMEHeader)
+			if p.FileName != "" {
+				typ := p.ContentType
+				if typ == "" {
+					typ = "application/octet-stream"
+				}
+				h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(p.FieldName), escapeQuotes(p.FileName)))
+				h.Set("Content-Type", typ)
+			} else {
+				h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(p.FieldName)))
+			}
+			w, err := mw.CreatePart(h)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			counted.r = p.Reader
+			if _, err = io.Copy(w, counted); err != nil {
+				pw.CloseWithError(errors.Wrapf(err, "http: upload part %s", p.FieldName))
+				return
+			}
+		}
+		pw.CloseWithError(mw.Close())
+	}()
+	return pr, mw.FormDataContentType()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+// Upload posts parts as a multipart form to uri, streaming their readers
+// without buffering them. progress, may be nil, is called as the parts are
+// sent. The body can not be rewound, so uploads are not retried.
+func (c *Client) Upload(ctx context.Context, uri string, parts []Part, progress Progress) ([]byte, error) {
+	body, contentType := multipartBody(parts, progress)
+	// NOTE unblock the writer if the request fails before reading it all.
+	defer body.Close()
+	req, err := http.NewRequest(http.MethodPost, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.read(ctx, req)
+}
+
+// Checksum verifies a download.
+type Checksum struct {
+	New func() hash.Hash // such as sha256.New
+	Sum string           // the expected hex digest
+}
+
+// Download gets uri into the file path. The body is written to path+".part"
+// first, which is renamed to path once complete and verified by sum, may
+// be nil. A download failing midway is resumed by calling Download again,
+// the part already written is asked for by a Range request. A mismatching
+// checksum removes the part, so the next call starts over.
+func (c *Client) Download(ctx context.Context, uri, path string, sum *Checksum, progress Progress) error {
+	part := path + ".part"
+	f, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	total := int64(-1)
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, size, ok := contentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return errors.Errorf("http: download %s unexpected Content-Range %q from %d", uri, resp.Header.Get("Content-Range"), offset)
+		}
+		total = size
+	case http.StatusRequestedRangeNotSatisfiable:
+		// NOTE the part may be the whole file already.
+		if _, size, ok := contentRange(resp.Header.Get("Content-Range")); !ok || size != offset {
+			f.Truncate(0)
+			return checkStatus(resp)
+		}
+		total = offset
+		resp.Body = http.NoBody
+	default:
+		if err = checkStatus(resp); err != nil {
+			return err
+		}
+		// the range is ignored, start over
+		if offset > 0 {
+			if err = f.Truncate(0); err != nil {
+				return err
+			}
+			if _, err = f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			offset = 0
+		}
+		if resp.ContentLength >= 0 {
+			total = resp.ContentLength
+		}
+	}
+	body := &progressReader{r: resp.Body, done: offset, total: total, progress: progress}
+	if _, err = io.Copy(f, body); err != nil {
+		return errors.Wrapf(err, "http: download %s at %d", uri, body.done)
+	}
+	if sum != nil {
+		if err = verify(f, sum); err != nil {
+			f.Close()
+			os.Remove(part)
+			return errors.Wrapf(err, "http: download %s", uri)
+		}
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(part, path)
+}
+
+// contentRange parses "bytes start-end/size", size is -1 if "*".
+func contentRange(s string) (start, size int64, ok bool) {
+	if !strings.HasPrefix(s, "bytes ") {
+		return 0, 0, false
+	}
+	s = s[len("bytes "):]
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return 0, 0, false
+	}
+	size = -1
+	if s[i+1:] != "*" {
+		var err error
+		if size, err = strconv.ParseInt(s[i+1:], 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if s[:i] == "*" {
+		return 0, size, true
+	}
+	j := strings.IndexByte(s[:i], '-')
+	if j < 0 {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(s[:j], 10, 64)
+	return start, size, err == nil
+}
+
+func verify(f *os.File, sum *Checksum) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	h := sum.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, sum.Sum) {
+		return errors.Errorf("checksum %s, want %s", got, sum.Sum)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// formEcho echoes the parts of multipart forms as name/filename/type=body.
+func formEcho() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var out []string
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			b, _ := ioutil.ReadAll(p)
+			typ := ""
+			if p.FileName() != "" {
+				typ = p.Header.Get("Content-Type")
+			}
+			out = append(out, p.FormName()+"/"+p.FileName()+"/"+typ+"="+string(b))
+		}
+		w.Write([]byte(strings.Join(out, ",")))
+	}))
+}
+
+func TestUpload(t *testing.T) {
+	srv := formEcho()
+	defer srv.Close()
+	c := newTestClient(new(fakeStat), 0)
+
+	var done, total int64
+	b, err := c.Upload(context.Background(), srv.URL, []Part{
+		{FieldName: "title", Reader: strings.NewReader("hi"), Size: 2},
+		{FieldName: "file", FileName: "a.txt", ContentType: "text/plain", Reader: strings.NewReader("hello"), Size: 5},
+	}, func(d, t int64) { done, total = d, t })
+	assert.NoError(t, err)
+	assert.Equal(t, "title//=hi,file/a.txt/text/plain=hello", string(b))
+	assert.Equal(t, int64(7), done)
+	assert.Equal(t, int64(7), total)
+
+	_, err = c.Upload(context.Background(), srv.URL, []Part{
+		{FieldName: "file", FileName: "a.bin", Reader: io.MultiReader(strings.NewReader("x"), errReader{}), Size: -1},
+	}, nil)
+	assert.Error(t, err, "a failing part fails the upload")
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("disk error") }
+
+func TestPostFile(t *testing.T) {
+	srv := formEcho()
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "upload")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "a.txt")
+	assert.NoError(t, ioutil.WriteFile(name, []byte("hello"), 0644))
+
+	b, err := PostFile("media", name, srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "media/a.txt/application/octet-stream=hello", string(b), "the part is named by fieldName")
+}
+
+func TestDownload(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	digest := sha256.Sum256([]byte(content))
+	sum := &Checksum{New: sha256.New, Sum: hex.EncodeToString(digest[:])}
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "data", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "data")
+	c := newTestClient(new(fakeStat), 0)
+
+	var done, total int64
+	progress := func(d, t int64) { done, total = d, t }
+	assert.NoError(t, c.Download(context.Background(), srv.URL, path, sum, progress))
+	b, _ := ioutil.ReadFile(path)
+	assert.Equal(t, content, string(b))
+	assert.Equal(t, int64(len(content)), done)
+	assert.Equal(t, int64(len(content)), total)
+	_, err = os.Stat(path + ".part")
+	assert.True(t, os.IsNotExist(err))
+
+	// resumes from the part
+	assert.NoError(t, ioutil.WriteFile(path+".part", []byte(content[:4000]), 0644))
+	ranges = nil
+	assert.NoError(t, c.Download(context.Background(), srv.URL, path, sum, progress))
+	b, _ = ioutil.ReadFile(path)
+	assert.Equal(t, content, string(b))
+	assert.Equal(t, []string{"bytes=4000-"}, ranges)
+
+	// a complete part is verified and renamed
+	assert.NoError(t, ioutil.WriteFile(path+".part", []byte(content), 0644))
+	assert.NoError(t, c.Download(context.Background(), srv.URL, path, sum, nil))
+	_, err = os.Stat(path + ".part")
+	assert.True(t, os.IsNotExist(err))
+
+	// a corrupt part fails the checksum and is removed
+	assert.NoError(t, ioutil.WriteFile(path+".part", []byte("garbage"), 0644))
+	err = c.Download(context.Background(), srv.URL, path, sum, nil)
+	assert.Error(t, err)
+	_, err = os.Stat(path + ".part")
+	assert.True(t, os.IsNotExist(err))
+	assert.NoError(t, c.Download(context.Background(), srv.URL, path, sum, nil))
+}
+
+func TestDownloadRangeIgnored(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("full"))
+	}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "data")
+
+	assert.NoError(t, ioutil.WriteFile(path+".part", []byte("fu"), 0644))
+	assert.NoError(t, newTestClient(new(fakeStat), 0).Download(context.Background(), srv.URL, path, nil, nil))
+	b, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "full", string(b), "starts over")
+}
@@ -0,0 +1,195 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/any-lyu/go.library/logs"
+	"github.com/any-lyu/go.library/stat"
+)
+
+// RoundTripperFunc adapts a func to http.RoundTripper.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a RoundTripper, it can be set as Config.Middlewares.
+// Like any RoundTripper, it must not modify the request, but a clone of it.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// Chain composes mws into one middleware, the first is the outermost: a
+// request runs through mws in order then the transport, and the response
+// back through them in reverse.
+//
+//	rt := http.Chain(
+//		http.NewLogging(),
+//		http.NewAuthHeader(token),
+//		http.NewSigning(http.HMACSigner("k1", secret)),
+//	).Then(http.DefaultTransport)
+func Chain(mws ...Middleware) Middleware {
+	mws = append([]Middleware(nil), mws...)
+	return func(rt http.RoundTripper) http.RoundTripper {
+		for i := len(mws) - 1; i >= 0; i-- {
+			rt = mws[i](rt)
+		}
+		return rt
+	}
+}
+
+// Then wraps rt by the middleware.
+func (m Middleware) Then(rt http.RoundTripper) http.RoundTripper {
+	return m(rt)
+}
+
+// endpoint is the url of req without query, which may carry secrets.
+func endpoint(req *http.Request) string {
+	return req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+}
+
+// NewLogging new a middleware logging requests with their status and
+// latency, failed ones as errors.
+func NewLogging() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				logs.Error("http: %s %s error(%v) %v", req.Method, endpoint(req), err, time.Since(start))
+				return nil, err
+			}
+			logs.Info("http: %s %s %d %v", req.Method, endpoint(req), resp.StatusCode, time.Since(start))
+			return resp, nil
+		})
+	}
+}
+
+// NewMetrics new a middleware reporting latency and code of requests by
+// url to s, "error" for failed requests. Client reports to Config.Stat so.
+func NewMetrics(s stat.Stat) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			uri := endpoint(req)
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			s.Timing(uri, int64(time.Since(start)/time.Millisecond))
+			if err != nil {
+				s.Incr(uri, "error")
+				return nil, err
+			}
+			s.Incr(uri, strconv.Itoa(resp.StatusCode))
+			return resp, nil
+		})
+	}
+}
+
+// NewAuthHeader new a middleware setting the Authorization header of
+// requests without one to the value of token, such as "Bearer " and a
+// token refreshed by the func.
+func NewAuthHeader(token func(ctx context.Context) (string, error)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Authorization") != "" {
+				return next.RoundTrip(req)
+			}
+			v, err := token(req.Context())
+			if err != nil {
+				closeBody(req)
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", v)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// Signer signs a request of body, setting headers of req.
+type Signer func(req *http.Request, body []byte) error
+
+// NewSigning new a middleware signing requests by sign, the body is read
+// into memory to be signed.
+func NewSigning(sign Signer) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body, err := readRequestBody(req)
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			setRequestBody(req, body)
+			if err = sign(req, body); err != nil {
+				return nil, err
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// HMACSigner signs requests by the HTTP signatures draft with hmac-sha256
+// of secret. It sets the Date header if missing, the Digest header of the
+// body and the Signature header over the request target, date and digest.
+func HMACSigner(keyID string, secret []byte) Signer {
+	return func(req *http.Request, body []byte) error {
+		if req.Header.Get("Date") == "" {
+			req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+		}
+		sum := sha256.Sum256(body)
+		req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]))
+		signing := fmt.Sprintf("(request-target): %s %s\ndate: %s\ndigest: %s",
+			strings.ToLower(req.Method), req.URL.RequestURI(), req.Header.Get("Date"), req.Header.Get("Digest"))
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signing))
+		req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="hmac-sha256",headers="(request-target) date digest",signature="%s"`,
+			keyID, base64.StdEncoding.EncodeToString(mac.Sum(nil))))
+		return nil
+	}
+}
+
+// readRequestBody reads the body of req, by GetBody if it can be rewound,
+// the body is closed.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return ioutil.ReadAll(body)
+	}
+	return ioutil.ReadAll(req.Body)
+}
+
+// setRequestBody sets body as the rewindable body of a cloned req.
+func setRequestBody(req *http.Request, body []byte) {
+	if body == nil {
+		return
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
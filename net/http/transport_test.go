@@ -0,0 +1,104 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// echo answers with the request headers named, and the body.
+func echo(names ...string) RoundTripperFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		var out []string
+		for _, name := range names {
+			out = append(out, req.Header.Get(name))
+		}
+		if req.Body != nil {
+			b, _ := ioutil.ReadAll(req.Body)
+			req.Body.Close()
+			out = append(out, string(b))
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(strings.Join(out, "|"))), Request: req}, nil
+	}
+}
+
+func tag(calls *[]string, name string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			*calls = append(*calls, ">"+name)
+			resp, err := next.RoundTrip(req)
+			*calls = append(*calls, "<"+name)
+			return resp, err
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	rt := Chain(tag(&calls, "a"), Chain(tag(&calls, "b"), tag(&calls, "c"))).Then(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls = append(calls, "rt")
+		return nil, errors.New("none")
+	}))
+	rt.RoundTrip(mustRequest("http://example.com"))
+	assert.Equal(t, []string{">a", ">b", ">c", "rt", "<c", "<b", "<a"}, calls)
+}
+
+func TestAuthHeader(t *testing.T) {
+	c := NewClient(&Config{
+		Transport:   echo("Authorization"),
+		Middlewares: []Middleware{NewLogging(), NewAuthHeader(func(context.Context) (string, error) { return "Bearer t1", nil })},
+		Stat:        new(fakeStat),
+	})
+	b, err := c.Get(context.Background(), "http://example.com/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer t1", string(b))
+
+	req := mustRequest("http://example.com/a")
+	req.Header.Set("Authorization", "Basic x")
+	var got []byte
+	assert.NoError(t, c.DoInto(context.Background(), req, &got))
+	assert.Equal(t, "Basic x", string(got), "set headers are kept")
+
+	failing := NewClient(&Config{
+		Transport:   echo(),
+		Middlewares: []Middleware{NewAuthHeader(func(context.Context) (string, error) { return "", errors.New("no token") })},
+		Stat:        new(fakeStat),
+	})
+	_, err = failing.Get(context.Background(), "http://example.com/a")
+	assert.Error(t, err)
+}
+
+func TestHMACSigner(t *testing.T) {
+	secret := []byte("secret")
+	c := NewClient(&Config{
+		Transport:   echo("Date", "Digest", "Signature"),
+		Middlewares: []Middleware{NewSigning(HMACSigner("k1", secret))},
+		Stat:        new(fakeStat),
+		Retries:     1,
+	})
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/pay?x=1", strings.NewReader(`{"a":1}`))
+	req.Header.Set("Date", "Tue, 01 Jan 2019 00:00:00 GMT")
+	var b []byte
+	assert.NoError(t, c.DoInto(context.Background(), req, &b))
+	got := strings.Split(string(b), "|")
+
+	sum := sha256.Sum256([]byte(`{"a":1}`))
+	digest := "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("(request-target): post /pay?x=1\ndate: Tue, 01 Jan 2019 00:00:00 GMT\ndigest: " + digest))
+	assert.Equal(t, []string{
+		"Tue, 01 Jan 2019 00:00:00 GMT",
+		digest,
+		`keyId="k1",algorithm="hmac-sha256",headers="(request-target) date digest",signature="` + base64.StdEncoding.EncodeToString(mac.Sum(nil)) + `"`,
+		`{"a":1}`,
+	}, got)
+	assert.Empty(t, req.Header.Get("Signature"), "the request is not modified")
+}
@@ -0,0 +1,65 @@
+package ip
+
+import (
+	"net"
+	"strings"
+)
+
+// ParseNets parses CIDRs or single ips into nets, e.g. trusted proxies.
+func ParseNets(cidrs ...string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, s := range cidrs {
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// ClientIP returns the client ip of a request from its remote addr and
+// X-Forwarded-For header. The header is only believed when the remote addr
+// is a trusted proxy, it is then walked from the right skipping trusted
+// proxies, so clients can not spoof it.
+func ClientIP(remoteAddr, forwardedFor string, trusted []*net.IPNet) string {
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+	if !contains(trusted, ip) || forwardedFor == "" {
+		return ip
+	}
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			return ip
+		}
+		ip = hop
+		if !contains(trusted, hop) {
+			break
+		}
+	}
+	return ip
+}
+
+func contains(nets []*net.IPNet, s string) bool {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
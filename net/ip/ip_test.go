@@ -54,3 +54,29 @@ func BenchmarkZone(b *testing.B) {
 		}
 	})
 }
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseNets("10.0.0.0/8", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		remote, xff, want string
+	}{
+		{"1.2.3.4:5678", "", "1.2.3.4"},
+		{"1.2.3.4:5678", "9.9.9.9", "1.2.3.4"},
+		{"10.0.0.2:80", "", "10.0.0.2"},
+		{"10.0.0.2:80", "9.9.9.9, 5.6.7.8, 10.0.0.1", "5.6.7.8"},
+		{"127.0.0.1:80", "10.1.1.1", "10.1.1.1"},
+		{"10.0.0.2:80", "bogus, 5.6.7.8", "5.6.7.8"},
+		{"10.0.0.2:80", "5.6.7.8, bogus", "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		if got := ClientIP(tt.remote, tt.xff, trusted); got != tt.want {
+			t.Errorf("ClientIP(%q, %q) = %q, want %q", tt.remote, tt.xff, got, tt.want)
+		}
+	}
+	if _, err = ParseNets("10.0.0.0/33"); err == nil {
+		t.Error("want error of bad cidr")
+	}
+}
@@ -0,0 +1,233 @@
+// Package cluster provides a redis backed rate.Limiter shared by every
+// instance of a service.
+//
+// Decisions are made atomically by lua scripts on cache/redis, using either
+// GCRA (smooth rate with burst) or a sliding window log (exact count within
+// a window). When redis is unreachable the limiter degrades to a local token
+// bucket with the same rate, so each process enforces the limit on its own.
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	redigo "github.com/garyburd/redigo/redis"
+	xrate "golang.org/x/time/rate"
+
+	"github.com/any-lyu/go.library/cache/redis"
+	"github.com/any-lyu/go.library/errors"
+	log "github.com/any-lyu/go.library/logs"
+	"github.com/any-lyu/go.library/rate"
+	xtime "github.com/any-lyu/go.library/time"
+)
+
+// Algorithm names.
+const (
+	// GCRA generic cell rate algorithm, allows Burst requests at once and
+	// then Limit requests per Period evenly spaced.
+	GCRA = "gcra"
+	// SlidingWindow sliding window log, allows at most Limit requests in any
+	// Period.
+	SlidingWindow = "window"
+)
+
+var _ rate.Limiter = &Limiter{}
+
+// Config cluster limiter config.
+type Config struct {
+	Algorithm string // GCRA or SlidingWindow, default GCRA.
+	Limit     int64  // requests allowed per Period.
+	Period    xtime.Duration
+	Burst     int64  // GCRA only, default Limit.
+	Prefix    string // redis key prefix, default "rate:".
+}
+
+func (c *Config) fix() {
+	if c.Algorithm == "" {
+		c.Algorithm = GCRA
+	}
+	if c.Limit <= 0 {
+		c.Limit = 100
+	}
+	if c.Period <= 0 {
+		c.Period = xtime.Duration(time.Second)
+	}
+	if c.Burst <= 0 {
+		c.Burst = c.Limit
+	}
+	if c.Prefix == "" {
+		c.Prefix = "rate:"
+	}
+}
+
+// Result is the outcome of one limiter decision.
+type Result struct {
+	Allowed bool
+	Limit   int64
+	// Remaining requests allowed right now, 0 when unknown.
+	Remaining int64
+	// RetryAfter is how long to wait before the next request may be allowed.
+	RetryAfter time.Duration
+	// ResetAfter is how long until the limiter is back to its full capacity.
+	ResetAfter time.Duration
+	// Local reports the decision was made by the local fallback.
+	Local bool
+}
+
+// Limiter is a cluster wide limiter bound to one redis key.
+type Limiter struct {
+	client *redis.Client
+	conf   *Config
+	key    string
+	local  *xrate.Limiter
+	// degraded is 1 while redis is unavailable, the state changes are
+	// logged once instead of every request.
+	degraded int32
+}
+
+// New new a cluster limiter on key, if conf nil use default conf.
+func New(client *redis.Client, key string, conf *Config) *Limiter {
+	if conf == nil {
+		conf = &Config{}
+	}
+	conf.fix()
+	return newLimiter(client, key, conf)
+}
+
+func newLimiter(client *redis.Client, key string, conf *Config) *Limiter {
+	every := time.Duration(conf.Period) / time.Duration(conf.Limit)
+	burst := conf.Burst
+	if conf.Algorithm == SlidingWindow {
+		burst = conf.Limit
+	}
+	return &Limiter{
+		client: client,
+		conf:   conf,
+		key:    conf.Prefix + key,
+		local:  xrate.NewLimiter(xrate.Every(every), int(burst)),
+	}
+}
+
+// Allow implement rate.Limiter.
+// if error is returned,no need to call done()
+func (l *Limiter) Allow(ctx context.Context) (func(rate.Op), error) {
+	res, err := l.Take(ctx)
+	if err != nil {
+		return func(rate.Op) {}, err
+	}
+	if !res.Allowed {
+		return func(rate.Op) {}, errors.ErrLimitExceed
+	}
+	return func(rate.Op) {}, nil
+}
+
+// Take takes one request from the limiter and reports the decision.
+// A non nil error is only returned when redis rejected the script, network
+// failures fall back to the local limiter.
+func (l *Limiter) Take(ctx context.Context) (res *Result, err error) {
+	conn, err := l.client.Pool.GetContext(ctx)
+	if err != nil {
+		return l.fallback(err), nil
+	}
+	defer conn.Close()
+	var reply []int64
+	switch l.conf.Algorithm {
+	case SlidingWindow:
+		reply, err = redigo.Int64s(_windowScript.Do(conn, l.key, micros(time.Duration(l.conf.Period)), l.conf.Limit, member()))
+	default:
+		interval := micros(time.Duration(l.conf.Period)) / l.conf.Limit
+		if interval <= 0 {
+			interval = 1
+		}
+		reply, err = redigo.Int64s(_gcraScript.Do(conn, l.key, interval, l.conf.Burst))
+	}
+	if err != nil {
+		if _, ok := err.(redigo.Error); ok {
+			return nil, errors.Wrapf(err, "rate/cluster: key:%s", l.key)
+		}
+		return l.fallback(err), nil
+	}
+	if len(reply) != 4 {
+		return nil, errors.Errorf("rate/cluster: key:%s unexpected reply %v", l.key, reply)
+	}
+	if atomic.CompareAndSwapInt32(&l.degraded, 1, 0) {
+		log.Info("rate/cluster: key:%s redis recovered, use cluster limiter", l.key)
+	}
+	return &Result{
+		Allowed:    reply[0] == 1,
+		Limit:      l.conf.Limit,
+		Remaining:  reply[1],
+		RetryAfter: time.Duration(reply[2]) * time.Microsecond,
+		ResetAfter: time.Duration(reply[3]) * time.Microsecond,
+	}, nil
+}
+
+func (l *Limiter) fallback(cause error) *Result {
+	if atomic.CompareAndSwapInt32(&l.degraded, 0, 1) {
+		log.Warn("rate/cluster: key:%s redis unavailable, use local limiter: %v", l.key, cause)
+	}
+	res := &Result{Limit: l.conf.Limit, Local: true}
+	r := l.local.Reserve()
+	if delay := r.Delay(); delay > 0 {
+		r.Cancel()
+		res.RetryAfter = delay
+		return res
+	}
+	res.Allowed = true
+	return res
+}
+
+// Group represents a class of cluster limiters sharing one config, keyed by
+// name.
+type Group struct {
+	mu     sync.RWMutex
+	client *redis.Client
+	conf   *Config
+	lims   map[string]*Limiter
+}
+
+// NewGroup new a limiter group, if conf nil use default conf.
+func NewGroup(client *redis.Client, conf *Config) *Group {
+	if conf == nil {
+		conf = &Config{}
+	}
+	conf.fix()
+	return &Group{
+		client: client,
+		conf:   conf,
+		lims:   make(map[string]*Limiter),
+	}
+}
+
+// Get get a limiter by a specified key, if limiter not exists then make a new one.
+func (g *Group) Get(key string) *Limiter {
+	g.mu.RLock()
+	lim, ok := g.lims[key]
+	g.mu.RUnlock()
+	if ok {
+		return lim
+	}
+	g.mu.Lock()
+	if lim, ok = g.lims[key]; !ok {
+		lim = newLimiter(g.client, key, g.conf)
+		g.lims[key] = lim
+	}
+	g.mu.Unlock()
+	return lim
+}
+
+func micros(d time.Duration) int64 {
+	return int64(math.Ceil(float64(d) / float64(time.Microsecond)))
+}
+
+// member returns a unique sliding window log entry.
+func member() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,106 @@
+package cluster
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	redigo "github.com/garyburd/redigo/redis"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/any-lyu/go.library/cache/redis"
+	"github.com/any-lyu/go.library/cache/redis/redistest"
+	"github.com/any-lyu/go.library/errors"
+	xtime "github.com/any-lyu/go.library/time"
+)
+
+// scripted answers EVALSHA with NOSCRIPT and EVAL with reply, recording the
+// EVAL arguments.
+func scripted(reply interface{}, got *[]string) redistest.HandlerFunc {
+	return func(cmd string, args []string) interface{} {
+		switch cmd {
+		case "EVALSHA":
+			return redistest.Error("NOSCRIPT No matching script")
+		case "EVAL":
+			*got = args
+			return reply
+		}
+		return redistest.Error("ERR unknown command")
+	}
+}
+
+func TestTakeGCRA(t *testing.T) {
+	var got []string
+	s := redistest.NewServer(scripted([]interface{}{1, 4, 0, 200000}, &got))
+	defer s.Close()
+
+	l := New(&redis.Client{Pool: s.Pool()}, "api", &Config{Limit: 5, Period: xtime.Duration(time.Second)})
+	res, err := l.Take(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.False(t, res.Local)
+	assert.Equal(t, int64(4), res.Remaining)
+	assert.Equal(t, 200*time.Millisecond, res.ResetAfter)
+	// script, numkeys, key, interval, burst
+	assert.Equal(t, []string{"1", "rate:api", "200000", "5"}, got[1:])
+}
+
+func TestAllowWindowDenied(t *testing.T) {
+	var got []string
+	s := redistest.NewServer(scripted([]interface{}{0, 0, 1500, 1500}, &got))
+	defer s.Close()
+
+	l := New(&redis.Client{Pool: s.Pool()}, "api", &Config{Algorithm: SlidingWindow, Limit: 10, Period: xtime.Duration(time.Minute)})
+	res, err := l.Take(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 1500*time.Microsecond, res.RetryAfter)
+	assert.Equal(t, "60000000", got[3])
+	assert.Equal(t, "10", got[4])
+	assert.Len(t, got, 6)
+
+	_, err = l.Allow(context.Background())
+	assert.Equal(t, errors.ErrLimitExceed, err)
+}
+
+func TestScriptError(t *testing.T) {
+	var got []string
+	s := redistest.NewServer(scripted(redistest.Error("WRONGTYPE Operation against a key holding the wrong kind of value"), &got))
+	defer s.Close()
+
+	l := New(&redis.Client{Pool: s.Pool()}, "api", nil)
+	_, err := l.Take(context.Background())
+	assert.Error(t, err)
+}
+
+func TestLocalFallback(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	pool := &redigo.Pool{Dial: func() (redigo.Conn, error) { return redigo.Dial("tcp", addr) }}
+
+	l := New(&redis.Client{Pool: pool}, "api", &Config{Limit: 2, Period: xtime.Duration(time.Hour)})
+	for i := 0; i < 2; i++ {
+		res, err := l.Take(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, res.Local)
+		assert.True(t, res.Allowed)
+	}
+	res, err := l.Take(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.True(t, res.RetryAfter > 0)
+	assert.Equal(t, int32(1), l.degraded)
+}
+
+func TestGroup(t *testing.T) {
+	g := NewGroup(&redis.Client{}, nil)
+	a := g.Get("a")
+	assert.True(t, a == g.Get("a"))
+	assert.True(t, a != g.Get("b"))
+	assert.Equal(t, "rate:a", a.key)
+}
@@ -0,0 +1,49 @@
+package cluster
+
+import (
+	redigo "github.com/garyburd/redigo/redis"
+)
+
+// All times are in microseconds taken from redis TIME, so instances with
+// skewed clocks still agree. Both scripts return
+// {allowed, remaining, retry_after, reset_after}.
+
+// _gcraScript KEYS[1] key, ARGV[1] emission interval, ARGV[2] burst.
+var _gcraScript = redigo.NewScript(1, `
+redis.replicate_commands()
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+local new_tat = tat + interval
+local diff = now - (new_tat - interval * burst)
+if diff < 0 then
+	return {0, 0, -diff, tat - now}
+end
+local ttl = new_tat - now
+redis.call("SET", KEYS[1], new_tat, "PX", math.ceil(ttl / 1000))
+return {1, math.floor(diff / interval), 0, ttl}
+`)
+
+// _windowScript KEYS[1] key, ARGV[1] window, ARGV[2] limit, ARGV[3] member.
+var _windowScript = redigo.NewScript(1, `
+redis.replicate_commands()
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, ARGV[3])
+	redis.call("PEXPIRE", KEYS[1], math.ceil(window / 1000))
+	return {1, limit - count - 1, 0, window}
+end
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+local retry = tonumber(oldest[2]) + window - now
+return {0, 0, retry, retry}
+`)
@@ -0,0 +1,85 @@
+package rbac
+
+import (
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/any-lyu/go.library/config"
+	log "github.com/any-lyu/go.library/logs"
+)
+
+// Enforcer checks requests against a policy which can be replaced at
+// runtime, safe for concurrent use.
+type Enforcer struct {
+	policy atomic.Value // *Policy
+}
+
+// New new an enforcer of config.
+func New(c *Config) (*Enforcer, error) {
+	e := new(Enforcer)
+	if err := e.Update(c); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Load new an enforcer of the config file at path, if watch is true the
+// policy is reloaded when the file changes. A bad reload is logged and the
+// previous policy kept.
+func Load(path string, watch bool) (*Enforcer, error) {
+	c := new(Config)
+	if err := config.LoadConfig(path, c); err != nil {
+		return nil, err
+	}
+	e, err := New(c)
+	if err != nil {
+		return nil, err
+	}
+	if watch {
+		v := config.NewViper(path)
+		v.OnConfigChange(func(fsnotify.Event) {
+			if err := e.reload(path); err != nil {
+				log.Error("rbac: reload %s error(%v)", path, err)
+				return
+			}
+			log.Info("rbac: reloaded %s", path)
+		})
+		v.WatchConfig()
+	}
+	return e, nil
+}
+
+func (e *Enforcer) reload(path string) error {
+	c := new(Config)
+	if err := config.LoadConfig(path, c); err != nil {
+		return err
+	}
+	return e.Update(c)
+}
+
+// Update replaces the policy with config.
+func (e *Enforcer) Update(c *Config) error {
+	p, err := NewPolicy(c)
+	if err != nil {
+		return err
+	}
+	e.policy.Store(p)
+	return nil
+}
+
+// Policy returns the current policy.
+func (e *Enforcer) Policy() *Policy {
+	return e.policy.Load().(*Policy)
+}
+
+// Allowed reports whether req is allowed by the current policy.
+func (e *Enforcer) Allowed(req *Request) bool {
+	return e.Policy().Allowed(req)
+}
+
+// Check returns errors.ErrPermission if req is not allowed by the current
+// policy.
+func (e *Enforcer) Check(req *Request) error {
+	return e.Policy().Check(req)
+}
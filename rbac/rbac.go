@@ -0,0 +1,244 @@
+// Package rbac decides permissions by roles and attributes.
+//
+// A policy grants rules to roles, a rule allows or denies actions on
+// resources. Resources are "/" separated paths matched by patterns where
+// "*" matches one segment, "**" matches the rest and "{name}" matches one
+// segment capturing it as the resource attribute name, used by conditions:
+//
+//	{
+//	  "roles": {
+//	    "viewer": {"rules": [{"actions": ["read"], "resources": ["orders/**"]}]},
+//	    "owner":  {"rules": [{"actions": ["update"], "resources": ["orders/{owner}/*"],
+//	                "when": {"resource.owner": "${subject.id}"}}]},
+//	    "admin":  {"inherits": ["viewer", "owner"],
+//	               "rules": [{"actions": ["*"], "resources": ["**"]}]}
+//	  }
+//	}
+//
+// Deny rules override allow rules, nothing is allowed by default.
+package rbac
+
+import (
+	"strings"
+
+	"github.com/any-lyu/go.library/errors"
+)
+
+// Effect of a rule.
+const (
+	Allow = "allow"
+	Deny  = "deny"
+)
+
+// Config policy config.
+type Config struct {
+	Roles map[string]*RoleConfig `json:"roles"`
+}
+
+// RoleConfig role config.
+type RoleConfig struct {
+	Inherits []string `json:"inherits"` // roles whose rules the role also has
+	Rules    []*Rule  `json:"rules"`
+}
+
+// Rule allows or denies actions on resources.
+type Rule struct {
+	Effect    string   `json:"effect"`    // allow or deny, default allow
+	Actions   []string `json:"actions"`   // action names, "*" for any
+	Resources []string `json:"resources"` // resource patterns
+	// When are the conditions of the rule, attribute to value. Attributes
+	// are subject.id, subject.<attr> and resource.<attr>, values are literal
+	// or an attribute reference as ${attr}.
+	When map[string]string `json:"when"`
+
+	patterns []pattern
+}
+
+// Subject the caller asking for a permission.
+type Subject struct {
+	ID    string
+	Roles []string
+	Attrs map[string]string
+}
+
+// Request a permission request.
+type Request struct {
+	Subject  *Subject
+	Action   string
+	Resource string
+	Attrs    map[string]string // resource attributes
+}
+
+// Policy a compiled policy config, safe for concurrent use.
+type Policy struct {
+	roles map[string][]*Rule // role to its rules, inherited ones included
+}
+
+// NewPolicy compile config into a policy.
+func NewPolicy(c *Config) (*Policy, error) {
+	p := &Policy{roles: make(map[string][]*Rule, len(c.Roles))}
+	for name, rc := range c.Roles {
+		for _, r := range rc.Rules {
+			if err := r.compile(); err != nil {
+				return nil, errors.Wrapf(err, "rbac: role %q", name)
+			}
+		}
+	}
+	for name := range c.Roles {
+		rules, err := resolve(c, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		p.roles[name] = rules
+	}
+	return p, nil
+}
+
+func resolve(c *Config, name string, path []string) ([]*Rule, error) {
+	for _, n := range path {
+		if n == name {
+			return nil, errors.Errorf("rbac: inherit cycle %s -> %s", strings.Join(path, " -> "), name)
+		}
+	}
+	rc, ok := c.Roles[name]
+	if !ok {
+		return nil, errors.Errorf("rbac: role %q inherits unknown role %q", path[len(path)-1], name)
+	}
+	rules := append([]*Rule(nil), rc.Rules...)
+	for _, in := range rc.Inherits {
+		inherited, err := resolve(c, in, append(path, name))
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, inherited...)
+	}
+	return rules, nil
+}
+
+func (r *Rule) compile() error {
+	switch r.Effect {
+	case "":
+		r.Effect = Allow
+	case Allow, Deny:
+	default:
+		return errors.Errorf("unknown effect %q", r.Effect)
+	}
+	if len(r.Actions) == 0 || len(r.Resources) == 0 {
+		return errors.New("rule without actions or resources")
+	}
+	r.patterns = r.patterns[:0]
+	for _, res := range r.Resources {
+		r.patterns = append(r.patterns, compilePattern(res))
+	}
+	return nil
+}
+
+// Allowed reports whether req is allowed.
+func (p *Policy) Allowed(req *Request) bool {
+	allowed := false
+	for _, role := range req.Subject.Roles {
+		for _, r := range p.roles[role] {
+			if !r.match(req) {
+				continue
+			}
+			if r.Effect == Deny {
+				return false
+			}
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+// Check returns errors.ErrPermission if req is not allowed.
+func (p *Policy) Check(req *Request) error {
+	if !p.Allowed(req) {
+		return errors.Wrapf(errors.ErrPermission, "rbac: %s %s", req.Action, req.Resource)
+	}
+	return nil
+}
+
+func (r *Rule) match(req *Request) bool {
+	if !matchAction(r.Actions, req.Action) {
+		return false
+	}
+	for _, pat := range r.patterns {
+		captures, ok := pat.match(req.Resource)
+		if ok && r.cond(req, captures) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAction(actions []string, action string) bool {
+	for _, a := range actions {
+		if a == "*" || a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) cond(req *Request, captures map[string]string) bool {
+	for attr, want := range r.When {
+		got, ok := lookup(req, captures, attr)
+		if !ok {
+			return false
+		}
+		if strings.HasPrefix(want, "${") && strings.HasSuffix(want, "}") {
+			if want, ok = lookup(req, captures, want[2:len(want)-1]); !ok {
+				return false
+			}
+		}
+		if got != want {
+			return false
+		}
+	}
+	return true
+}
+
+func lookup(req *Request, captures map[string]string, attr string) (v string, ok bool) {
+	switch {
+	case attr == "subject.id":
+		return req.Subject.ID, true
+	case strings.HasPrefix(attr, "subject."):
+		v, ok = req.Subject.Attrs[attr[len("subject."):]]
+	case strings.HasPrefix(attr, "resource."):
+		name := attr[len("resource."):]
+		if v, ok = captures[name]; !ok {
+			v, ok = req.Attrs[name]
+		}
+	}
+	return
+}
+
+// pattern a compiled resource pattern.
+type pattern []string
+
+func compilePattern(s string) pattern {
+	return strings.Split(strings.Trim(s, "/"), "/")
+}
+
+func (p pattern) match(resource string) (captures map[string]string, ok bool) {
+	segs := strings.Split(strings.Trim(resource, "/"), "/")
+	for i, part := range p {
+		if part == "**" {
+			return captures, true
+		}
+		if i >= len(segs) {
+			return nil, false
+		}
+		switch {
+		case part == "*":
+		case len(part) > 2 && part[0] == '{' && part[len(part)-1] == '}':
+			if captures == nil {
+				captures = make(map[string]string)
+			}
+			captures[part[1:len(part)-1]] = segs[i]
+		case part != segs[i]:
+			return nil, false
+		}
+	}
+	return captures, len(p) == len(segs)
+}
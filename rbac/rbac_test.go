@@ -0,0 +1,100 @@
+package rbac
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/any-lyu/go.library/errors"
+)
+
+const policy = `{
+  "roles": {
+    "viewer": {"rules": [{"actions": ["read"], "resources": ["orders/**"]}]},
+    "owner": {"rules": [{"actions": ["update"], "resources": ["orders/{owner}/*"],
+      "when": {"resource.owner": "${subject.id}"}}]},
+    "staff": {"inherits": ["viewer", "owner"], "rules": [
+      {"effect": "deny", "actions": ["*"], "resources": ["orders/*/secret"]},
+      {"actions": ["read"], "resources": ["reports/*"], "when": {"subject.team": "finance"}}
+    ]},
+    "admin": {"rules": [{"actions": ["*"], "resources": ["**"]}]}
+  }
+}`
+
+func testConfig(t *testing.T) *Config {
+	c := new(Config)
+	assert.NoError(t, json.Unmarshal([]byte(policy), c))
+	return c
+}
+
+func TestPolicy(t *testing.T) {
+	p, err := NewPolicy(testConfig(t))
+	assert.NoError(t, err)
+
+	tom := &Subject{ID: "tom", Roles: []string{"staff"}, Attrs: map[string]string{"team": "finance"}}
+	tests := []struct {
+		sub      *Subject
+		action   string
+		resource string
+		allowed  bool
+	}{
+		{tom, "read", "orders/jim/1", true},
+		{tom, "update", "orders/tom/1", true},
+		{tom, "update", "orders/jim/1", false},
+		{tom, "update", "orders/tom/1/items", false},
+		{tom, "read", "orders/tom/secret", false},
+		{tom, "read", "reports/2019", true},
+		{&Subject{ID: "jim", Roles: []string{"staff"}}, "read", "reports/2019", false},
+		{&Subject{ID: "jim", Roles: []string{"viewer"}}, "delete", "orders/jim/1", false},
+		{&Subject{ID: "root", Roles: []string{"admin"}}, "delete", "anything/at/all", true},
+		{&Subject{ID: "nobody"}, "read", "orders/jim/1", false},
+	}
+	for _, tt := range tests {
+		req := &Request{Subject: tt.sub, Action: tt.action, Resource: tt.resource}
+		assert.Equal(t, tt.allowed, p.Allowed(req), "%s %s %s", tt.sub.ID, tt.action, tt.resource)
+	}
+
+	err = p.Check(&Request{Subject: tom, Action: "delete", Resource: "orders/tom/1"})
+	assert.Equal(t, errors.ErrPermission, errors.Cause(err))
+}
+
+func TestPolicyInvalid(t *testing.T) {
+	_, err := NewPolicy(&Config{Roles: map[string]*RoleConfig{
+		"a": {Inherits: []string{"b"}},
+		"b": {Inherits: []string{"a"}},
+	}})
+	assert.Error(t, err)
+
+	_, err = NewPolicy(&Config{Roles: map[string]*RoleConfig{"a": {Inherits: []string{"missing"}}}})
+	assert.Error(t, err)
+
+	_, err = NewPolicy(&Config{Roles: map[string]*RoleConfig{
+		"a": {Rules: []*Rule{{Effect: "maybe", Actions: []string{"read"}, Resources: []string{"x"}}}},
+	}})
+	assert.Error(t, err)
+}
+
+func TestEnforcerUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rbac")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "policy.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(policy), 0644))
+
+	e, err := Load(path, false)
+	assert.NoError(t, err)
+	req := &Request{Subject: &Subject{ID: "jim", Roles: []string{"viewer"}}, Action: "read", Resource: "orders/jim/1"}
+	assert.True(t, e.Allowed(req))
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"roles": {"viewer": {}}}`), 0644))
+	assert.NoError(t, e.reload(path))
+	assert.False(t, e.Allowed(req))
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"roles": {"viewer": {"inherits": ["x"]}}}`), 0644))
+	assert.Error(t, e.reload(path))
+	assert.False(t, e.Allowed(req))
+}
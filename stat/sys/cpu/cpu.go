@@ -109,3 +109,8 @@ func GetInfo() Info {
 		Quota:     quota,
 	}
 }
+
+// Usage returns the cpu use ratio in per mille, refreshed every 250ms.
+func Usage() uint64 {
+	return atomic.LoadUint64(&usage)
+}